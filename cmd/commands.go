@@ -0,0 +1,459 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bttger/markdown-flashcards/internal"
+)
+
+const defaultNumberCards = 20
+
+// Flags that correspond to settings of the front matter.
+var frontMatterFlags = map[string]string{
+	"number":          internal.SettingNumberCards,
+	"future-days-due": internal.SettingFutureDaysDue,
+	"wrap-lines":      internal.SettingWrapLines,
+	"sequential":      internal.SettingSequential,
+	"show-category":   internal.SettingShowCategory,
+	"git-commit":      internal.SettingGitCommit,
+	"type-answer":     internal.SettingTypeAnswer,
+}
+
+const filesDescription = "Directories are searched recursively for markdown files. Each card's progress is written\n" +
+	"back to the file it came from."
+
+// sessionFlags registers the flags shared by the study and test command.
+func sessionFlags(fs *flagSet, session *internal.Session) {
+	fs.Bool(&session.Sequential, "sequential", "s", "Show flashcards in sequential order as in the markdown file. "+
+		"The default behavior is to\nshow flashcards in random order.")
+	fs.Bool(&session.ShowCategory, "show-category", "o", "Show the category of each flashcard.")
+	fs.String(&session.Category, "category", "c", "category", "Show only flashcards of the specified category. "+
+		"A category is a first-level heading in the\nmarkdown file. A category can be specified by a "+
+		"case-insensitive prefix of the heading.")
+	fs.Bool(&session.ChooseCategories, "choose-category", "C", "Interactively choose the category to study.")
+	fs.Uint(&session.WrapLines, "wrap-lines", "w", "line_length", "Wrap lines to a maximum length. Only breaks "+
+		"lines at whitespaces. Defaults to terminal width.")
+	fs.Bool(&session.TypeAnswer, "type-answer", "a", "Type the answer before the back side is shown. The answer is "+
+		"compared to the\nback side, or to a line starting with 'Answer:' if it has one, and a grade is\nsuggested. "+
+		"Can also be enabled with 'typeAnswer: true' in the front matter.")
+}
+
+// openFiles Opens the files of the session and prints the warnings of the files to stderr.
+func openFiles(session *internal.Session, args []string) error {
+	err := session.OpenFile(args...)
+	for _, f := range session.Files {
+		for _, w := range f.Warnings {
+			fmt.Fprintln(os.Stderr, w)
+		}
+	}
+	// Only errors in the arguments print the usage.
+	if errors.Is(err, internal.ErrLocked) || errors.Is(err, internal.ErrParse) || errors.Is(err, internal.ErrIO) {
+		return err
+	} else if err != nil {
+		return usageError{err}
+	}
+	return nil
+}
+
+// printJSON Prints the value as indented JSON to the standard output.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// runSession Opens the files and starts the study session.
+func runSession(fs *flagSet, session *internal.Session, args []string) error {
+	if err := openFiles(session, args); err != nil {
+		return err
+	}
+	defer session.Close()
+
+	flagsSet := make(map[string]bool)
+	for flagName, setting := range frontMatterFlags {
+		if fs.Changed(flagName) {
+			flagsSet[setting] = true
+		}
+	}
+	if session.TestMode {
+		// The number of cards in test mode doesn't depend on the front matter.
+		flagsSet[internal.SettingNumberCards] = true
+	}
+	session.ApplyFrontMatter(flagsSet)
+
+	if err := session.CheckCategory(); err != nil {
+		return errors.New("invalid category specified")
+	}
+
+	printDebugHelp(*session)
+	if session.ChooseCategories && session.Category == "" {
+		session.ChooseCategory()
+	}
+	if err := session.Start(); err != nil {
+		return err
+	}
+	printDebugHelp(*session)
+	return nil
+}
+
+var studyCommand = &command{
+	name:    "study",
+	args:    "[file|directory|glob...]",
+	summary: "Study the cards that are due (default command).",
+	description: "Study the cards of the given files that are due today. All cards are studied in one session.\n" +
+		filesDescription,
+	setup: func(fs *flagSet) func(args []string) error {
+		session := &internal.Session{NumberCards: defaultNumberCards}
+		sessionFlags(fs, session)
+		fs.Uint(&session.NumberCards, "number", "n", "number_flashcards", "Learn n cards during the session. "+
+			"Set it to 0 to study all cards that are due to today.\nDefaults to 20.")
+		fs.Uint(&session.FutureDaysDue, "future-days-due", "f", "days", "Usually a flashcard is due on a "+
+			"particular date. If you want to learn flashcards\nbefore they are due, you can specify the number of "+
+			"days in the future when a flashcard\nshould be due. This might be helpful in the case when you have "+
+			"no cards due for today's\nlearning session. Cards where the due date was missed will be added "+
+			"anyway. Defaults to 0.")
+		fs.Bool(&session.GitCommit, "git-commit", "g", "Commit the flashcard files and their review histories to "+
+			"their git repository\nafter the session. The commit message summarises the session. Files outside of "+
+			"a\nrepository are skipped, and nothing is committed if unrelated changes are staged. Can\nalso be "+
+			"enabled with 'gitCommit: true' in the front matter.")
+		return func(args []string) error {
+			return runSession(fs, session, args)
+		}
+	},
+}
+
+var testCommand = &command{
+	name:    "test",
+	args:    "[file|directory|glob...]",
+	summary: "Test yourself with random cards without changing their progress.",
+	description: "Test yourself with random flashcards regardless of their due date. The progress of the cards\n" +
+		"is not changed.",
+	setup: func(fs *flagSet) func(args []string) error {
+		session := &internal.Session{TestMode: true}
+		sessionFlags(fs, session)
+		fs.Uint(&session.NumberCards, "number", "n", "number_flashcards", "Test yourself with n cards. Defaults "+
+			"to all cards.")
+		return func(args []string) error {
+			return runSession(fs, session, args)
+		}
+	},
+}
+
+var listCommand = &command{
+	name:    "list",
+	args:    "[file|directory|glob...]",
+	summary: "List the cards with their box and due date.",
+	description: "Lists the cards with their box and due date. With --json, the cards, the number of due cards\n" +
+		"per file and category, the next due date, and the number of cards per box are printed as a\n" +
+		"JSON object instead; its fields are documented in the README and stay compatible.",
+	setup: func(fs *flagSet) func(args []string) error {
+		session := &internal.Session{ReadOnly: true}
+		var jsonOutput bool
+		fs.String(&session.Category, "category", "c", "category", "Only list the cards of the specified category.")
+		fs.Bool(&jsonOutput, "json", "", "Print the cards and their due counts as a JSON object, e.g. for a script.")
+		return func(args []string) error {
+			if err := openFiles(session, args); err != nil {
+				return err
+			}
+			if err := session.CheckCategory(); err != nil {
+				return errors.New("invalid category specified")
+			}
+			if jsonOutput {
+				return printJSON(session.Report(true))
+			}
+			session.PrintCards()
+			return nil
+		}
+	},
+}
+
+var dueCommand = &command{
+	name:    "due",
+	args:    "[file|directory|glob...]",
+	summary: "Show the number of cards that are due today and in the next days.",
+	description: "Shows the number of cards that are due today and within the next days per file and category.\n" +
+		"The files are neither locked nor changed, so the command can run from a shell prompt or a\n" +
+		"status bar. With --json, the counts are printed as a JSON object (see 'mdfc help list').",
+	setup: func(fs *flagSet) func(args []string) error {
+		session := &internal.Session{ReadOnly: true, FutureDaysDue: 7}
+		var jsonOutput bool
+		fs.String(&session.Category, "category", "c", "category", "Only count the cards of the specified category.")
+		fs.Uint(&session.FutureDaysDue, "days", "d", "days", "Number of days after today in which cards are "+
+			"upcoming. Defaults to 7.")
+		fs.Bool(&jsonOutput, "json", "", "Print the counts as a JSON object, e.g. for a status bar.")
+		return func(args []string) error {
+			if err := openFiles(session, args); err != nil {
+				return err
+			}
+			if err := session.CheckCategory(); err != nil {
+				return errors.New("invalid category specified")
+			}
+			if jsonOutput {
+				return printJSON(session.Report(false))
+			}
+			session.PrintDueCounts()
+			return nil
+		}
+	},
+}
+
+var statsCommand = &command{
+	name:    "stats",
+	args:    "[file|directory|glob...]",
+	summary: "Show statistics about your reviews and the upcoming due cards.",
+	description: "Shows statistics about the review history of the given files: the retention rate, reviews per\n" +
+		"day, streaks, the hardest cards, and the number of cards due per category in the next days.\n" +
+		"The review history of a file is stored next to it with the suffix '.log.jsonl'.",
+	setup: func(fs *flagSet) func(args []string) error {
+		session := &internal.Session{ReadOnly: true}
+		var days uint = 7
+		fs.String(&session.Category, "category", "c", "category", "Only show the forecast of the specified "+
+			"category.")
+		fs.Uint(&days, "days", "d", "days", "Number of days of the forecast. Defaults to 7.")
+		return func(args []string) error {
+			if days == 0 {
+				return usageError{errors.New("the forecast needs at least one day")}
+			}
+			if err := openFiles(session, args); err != nil {
+				return err
+			}
+			if err := session.CheckCategory(); err != nil {
+				return errors.New("invalid category specified")
+			}
+			return session.PrintStats(int(days))
+		}
+	},
+}
+
+var lintCommand = &command{
+	name:    "lint",
+	args:    "[file|directory|glob...]",
+	summary: "Check the files for problems without studying them.",
+	description: "Checks the given files for problems: cards without a front or back side, cards before the first\n" +
+		"category, duplicate or malformed IDs, IDs that are used in several files, invalid metadata,\n" +
+		"metadata outside of a heading, duplicate questions, very long questions, and problems of the\n" +
+		"markdown structure such as unclosed code fences. Exits with status 1 if problems were found.",
+	setup: func(fs *flagSet) func(args []string) error {
+		var fix, jsonOutput bool
+		fs.Bool(&fix, "fix", "", "Repair the problems that can be repaired safely: generate new IDs for duplicate "+
+			"or\nmalformed ones, repair invalid metadata, and remove metadata outside of headings.")
+		fs.Bool(&jsonOutput, "json", "", "Print the problems as a JSON array, e.g. for an editor integration.")
+		return func(args []string) error {
+			files, err := internal.ExpandPaths(args)
+			if err != nil {
+				return usageError{err}
+			}
+			issues := make([]internal.Issue, 0)
+			for _, f := range files {
+				fileIssues, err := internal.Lint(f, fix)
+				if err != nil {
+					return err
+				}
+				issues = append(issues, fileIssues...)
+			}
+			collisions, err := internal.CheckIds(files)
+			if err != nil {
+				return err
+			}
+			issues = append(issues, collisions...)
+
+			if jsonOutput {
+				if err = printJSON(issues); err != nil {
+					return err
+				}
+			} else {
+				for _, i := range issues {
+					fmt.Println(i)
+				}
+			}
+			remaining := 0
+			for _, i := range issues {
+				if !i.Fixed {
+					remaining++
+				}
+			}
+			if remaining > 0 {
+				return fmt.Errorf("%d problems found", remaining)
+			}
+			return nil
+		}
+	},
+}
+
+var reidCommand = &command{
+	name:    "reid",
+	args:    "[file|directory|glob...]",
+	summary: "Give new IDs to cards whose ID is used in several files.",
+	description: "Makes the card IDs unique across the given files, e.g. after merging decks or copying cards\n" +
+		"between files. If a card has the ID of a card in an earlier file, it gets a new ID; the first\n" +
+		"card keeps its ID. The reviews of the card in the review history of its file are moved to the\n" +
+		"new ID. Duplicate IDs within a file are replaced as well.",
+	setup: func(fs *flagSet) func(args []string) error {
+		var dryRun, jsonOutput bool
+		fs.Bool(&dryRun, "dry-run", "", "Only print the cards that would get a new ID without changing the files.")
+		fs.Bool(&jsonOutput, "json", "", "Print the cards with their old and new ID as a JSON array.")
+		return func(args []string) error {
+			files, err := internal.ExpandPaths(args)
+			if err != nil {
+				return usageError{err}
+			}
+			rekeys, err := internal.Reid(files, dryRun)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				if rekeys == nil {
+					rekeys = make([]internal.Rekey, 0)
+				}
+				return printJSON(rekeys)
+			}
+			for _, r := range rekeys {
+				fmt.Println(r)
+			}
+			if len(rekeys) == 0 {
+				fmt.Println("All card IDs are unique.")
+			}
+			return nil
+		}
+	},
+}
+
+var importCommand = &command{
+	name:    "import",
+	args:    "anki <file.apkg> | csv <file.csv>",
+	summary: "Convert cards of another program to a markdown file.",
+	description: "Converts the notes of an Anki package (.apkg) to a new markdown file. Each deck becomes a\n" +
+		"category, fields are converted from html to markdown, and the progress of the cards is kept.\n" +
+		"Media files are extracted to a directory next to the markdown file. Packages of Anki 2.1.50\n" +
+		"and newer need to be exported with 'Support older Anki versions' enabled.\n\n" +
+		"A CSV file needs a header row with the columns Front, Back, Category, Id, Variant, Box, and\n" +
+		"Due (see 'mdfc export csv'); only Front is required. If the markdown file already exists, the\n" +
+		"rows are merged into it by their ID: existing cards are updated and the other rows are added\n" +
+		"as new cards at the end of their category.",
+	setup: func(fs *flagSet) func(args []string) error {
+		var output string
+		var byTag, tsv bool
+		fs.String(&output, "output", "o", "file", "Path of the markdown file to create. Defaults to the name of the "+
+			"package with the\nsuffix '.md' in the current directory.")
+		fs.Bool(&byTag, "tags", "t", "Use the first tag of each note as its category instead of its deck.")
+		fs.Bool(&tsv, "tsv", "", "Read a CSV file with tabs as separators. This is the default for files with the\n"+
+			"suffix '.tsv'.")
+		return func(args []string) error {
+			if len(args) != 2 || args[0] != "anki" && args[0] != "csv" {
+				return usageError{errors.New("specify the format 'anki' or 'csv' and exactly one file")}
+			}
+			path := args[1]
+			if output == "" {
+				output = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".md"
+			}
+			var summary internal.ImportSummary
+			var err error
+			if args[0] == "csv" {
+				summary, err = internal.ImportCSV(path, output, csvSeparator(tsv, path))
+			} else {
+				summary, err = internal.ImportAnki(path, output, byTag)
+			}
+			for _, w := range summary.Warnings {
+				fmt.Fprintln(os.Stderr, w)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println(summary)
+			return nil
+		}
+	},
+}
+
+// csvSeparator Returns the separator of the columns of a CSV file: a tab if it is requested or the file has the
+// suffix '.tsv', otherwise a comma.
+func csvSeparator(tsv bool, path string) rune {
+	if tsv || strings.EqualFold(filepath.Ext(path), ".tsv") {
+		return '\t'
+	}
+	return ','
+}
+
+var exportCommand = &command{
+	name:    "export",
+	args:    "anki|csv [file|directory|glob...]",
+	summary: "Convert the cards to the format of another program.",
+	description: "Writes the cards as a text file that Anki can import (File > Import, Anki 2.1.55 or newer).\n" +
+		"Each category becomes a subdeck of a deck named after its file, and fronts and backs are\n" +
+		"rendered to html. The card ID is the GUID of the note, so that importing the file again\n" +
+		"updates the notes instead of adding them again; new cards get their ID written to their\n" +
+		"file first. The progress of the cards is not exported.\n\n" +
+		"The format 'csv' writes a CSV file (RFC 4180) with the columns Front, Back, Category, Id,\n" +
+		"Variant, Box, and Due and one row per card, e.g. to edit the cards and their due dates in a\n" +
+		"spreadsheet and to import them again with 'mdfc import csv'.",
+	setup: func(fs *flagSet) func(args []string) error {
+		// The files are not opened read-only, so that the IDs of new cards are stable.
+		session := &internal.Session{}
+		var output, deck string
+		var tsv bool
+		fs.String(&output, "output", "o", "file", "Write to the file instead of the standard output.")
+		fs.String(&deck, "deck", "d", "deck", "Name of the deck the categories become subdecks of. Defaults to the "+
+			"name of the\nfile of each card.")
+		fs.String(&session.Category, "category", "c", "category", "Only export the cards of the specified category.")
+		fs.Bool(&tsv, "tsv", "", "Write a CSV file with tabs as separators. This is the default for an output file "+
+			"with\nthe suffix '.tsv'.")
+		return func(args []string) error {
+			if len(args) == 0 || args[0] != "anki" && args[0] != "csv" {
+				return usageError{errors.New("specify the format 'anki' or 'csv'")}
+			}
+			if err := openFiles(session, args[1:]); err != nil {
+				return err
+			}
+			defer session.Close()
+			if err := session.CheckCategory(); err != nil {
+				return errors.New("invalid category specified")
+			}
+			export := func(w io.Writer) error {
+				if args[0] == "csv" {
+					return session.ExportCSV(w, csvSeparator(tsv, output))
+				}
+				return session.ExportAnki(w, deck)
+			}
+			if output == "" {
+				return export(os.Stdout)
+			}
+			var b strings.Builder
+			if err := export(&b); err != nil {
+				return err
+			}
+			if err := os.WriteFile(output, []byte(b.String()), 0644); err != nil {
+				return &internal.IOError{Op: "write", Path: output, Err: err}
+			}
+			return nil
+		}
+	},
+}
+
+var shareCommand = &command{
+	name:    "share",
+	args:    "[file|directory|glob...]",
+	summary: "Create a copy of the files without your learning progress.",
+	description: "Creates a copy of each flashcard file with the suffix '.share.md'. This file resets the\n" +
+		"learning progress of all flashcards. This is useful if you want to share your flashcards.",
+	setup: func(fs *flagSet) func(args []string) error {
+		return func(args []string) error {
+			files, err := internal.ExpandPaths(args)
+			if err != nil {
+				return usageError{err}
+			}
+			for _, f := range files {
+				if err = internal.CreateCopyToShare(f); err != nil {
+					return err
+				}
+				fmt.Println("Created", strings.TrimSuffix(f, ".md")+".share.md")
+			}
+			return nil
+		}
+	},
+}
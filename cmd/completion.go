@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandFlags Returns the flags of the command as they are written on the command line, e.g. `-n` and `--number`.
+func commandFlags(c *command) (names []string) {
+	var help bool
+	fs, _ := c.newFlagSet(&help)
+	for _, f := range fs.flags {
+		if f.short != "" {
+			names = append(names, "-"+f.short)
+		}
+		names = append(names, "--"+f.long)
+	}
+	return names
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// printCompletion Prints the completion script for the given shell.
+func printCompletion(shell string) error {
+	switch shell {
+	case "bash":
+		printBashCompletion()
+	case "zsh":
+		// zsh can load bash completion functions.
+		fmt.Println("autoload -U +X bashcompinit && bashcompinit")
+		printBashCompletion()
+	case "fish":
+		printFishCompletion()
+	default:
+		return usageError{fmt.Errorf("unsupported shell: %s", shell)}
+	}
+	return nil
+}
+
+func printBashCompletion() {
+	fmt.Println("# bash completion for mdfc")
+	fmt.Println("_mdfc() {")
+	fmt.Println(`	local cur="${COMP_WORDS[COMP_CWORD]}" cmd="` + defaultCommand + `" flags`)
+	fmt.Printf("\tlocal commands=%q\n", strings.Join(commandNames(), " "))
+	fmt.Println(`	if [[ " $commands " == *" ${COMP_WORDS[1]} "* ]]; then`)
+	fmt.Println(`		cmd="${COMP_WORDS[1]}"`)
+	fmt.Println(`	elif [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then`)
+	fmt.Println(`		COMPREPLY=($(compgen -W "$commands" -- "$cur") $(compgen -f -- "$cur"))`)
+	fmt.Println(`		return`)
+	fmt.Println(`	fi`)
+	fmt.Println(`	case "$cmd" in`)
+	for _, c := range commands {
+		fmt.Printf("\t%s) flags=%q ;;\n", c.name, strings.Join(commandFlags(c), " "))
+	}
+	fmt.Println(`	esac`)
+	fmt.Println(`	case "$cmd" in`)
+	fmt.Println(`	help) COMPREPLY=($(compgen -W "$commands" -- "$cur")) ;;`)
+	fmt.Println(`	completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")) ;;`)
+	fmt.Println(`	*)`)
+	fmt.Println(`		if [[ $cur == -* ]]; then`)
+	fmt.Println(`			COMPREPLY=($(compgen -W "$flags" -- "$cur"))`)
+	fmt.Println(`		else`)
+	fmt.Println(`			COMPREPLY=($(compgen -f -- "$cur"))`)
+	fmt.Println(`		fi`)
+	fmt.Println(`		;;`)
+	fmt.Println(`	esac`)
+	fmt.Println("}")
+	fmt.Println("complete -o filenames -F _mdfc mdfc")
+}
+
+func printFishCompletion() {
+	fmt.Println("# fish completion for mdfc")
+	fmt.Printf("set -l mdfc_commands %s\n", strings.Join(commandNames(), " "))
+	for _, c := range commands {
+		fmt.Printf("complete -c mdfc -n \"not __fish_seen_subcommand_from $mdfc_commands\" -a %s -d %q\n",
+			c.name, c.summary)
+	}
+	fmt.Println("complete -c mdfc -n \"__fish_seen_subcommand_from help\" -f -a \"$mdfc_commands\"")
+	fmt.Println("complete -c mdfc -n \"__fish_seen_subcommand_from completion\" -f -a \"bash zsh fish\"")
+	for _, c := range commands {
+		var help bool
+		fs, _ := c.newFlagSet(&help)
+		condition := fmt.Sprintf("__fish_seen_subcommand_from %s", c.name)
+		if c.name == defaultCommand {
+			condition = "not __fish_seen_subcommand_from $mdfc_commands; or " + condition
+		}
+		for _, f := range fs.flags {
+			line := fmt.Sprintf("complete -c mdfc -n \"%s\" -l %s", condition, f.long)
+			if f.short != "" {
+				line += " -s " + f.short
+			}
+			if f.valueName != "" {
+				line += " -r"
+			}
+			// Use the first sentence of the usage as description.
+			usage, _, _ := strings.Cut(strings.ReplaceAll(f.usage, "\n", " "), ". ")
+			fmt.Printf("%s -d %q\n", line, strings.TrimSuffix(usage, "."))
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flag is a command-line option with a long name and an optional single-character short name.
+type flag struct {
+	long, short string
+	// Name of the flag's value in the help message. Empty for boolean flags.
+	valueName string
+	usage     string
+	set       func(value string) error
+	changed   bool
+}
+
+// flagSet parses the flags of a subcommand. In addition to the syntax of the standard flag package, it supports
+// combined short flags (`-so`), values attached to short flags (`-n5`), `--flag=value`, and flags after positional
+// arguments. All arguments after `--` are positional.
+type flagSet struct {
+	flags []*flag
+}
+
+func (fs *flagSet) add(f *flag) {
+	fs.flags = append(fs.flags, f)
+}
+
+// Bool defines a boolean flag.
+func (fs *flagSet) Bool(p *bool, long, short, usage string) {
+	fs.add(&flag{long: long, short: short, usage: usage, set: func(value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*p = b
+		return nil
+	}})
+}
+
+// Uint defines a flag with a non-negative number as value.
+func (fs *flagSet) Uint(p *uint, long, short, valueName, usage string) {
+	fs.add(&flag{long: long, short: short, valueName: valueName, usage: usage, set: func(value string) error {
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return errors.New("must be a non-negative number")
+		}
+		*p = uint(n)
+		return nil
+	}})
+}
+
+// String defines a flag with a string value.
+func (fs *flagSet) String(p *string, long, short, valueName, usage string) {
+	fs.add(&flag{long: long, short: short, valueName: valueName, usage: usage, set: func(value string) error {
+		*p = value
+		return nil
+	}})
+}
+
+// Changed Returns true if the flag with the given long name was specified on the command line.
+func (fs *flagSet) Changed(long string) bool {
+	for _, f := range fs.flags {
+		if f.long == long {
+			return f.changed
+		}
+	}
+	return false
+}
+
+func (fs *flagSet) lookup(name string, short bool) *flag {
+	for _, f := range fs.flags {
+		if (short && f.short == name) || (!short && f.long == name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// Parse parses the flags of the given arguments and returns the positional arguments.
+func (fs *flagSet) Parse(args []string) (positional []string, err error) {
+	setFlag := func(f *flag, name, value string) error {
+		if err := f.set(value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+		}
+		f.changed = true
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(positional, args[i+1:]...), nil
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			f := fs.lookup(name, false)
+			if f == nil {
+				return nil, fmt.Errorf("unknown flag: --%s", name)
+			}
+			if !hasValue {
+				if f.valueName == "" {
+					value = "true"
+				} else if i+1 < len(args) {
+					i++
+					value = args[i]
+				} else {
+					return nil, fmt.Errorf("missing value for --%s", name)
+				}
+			}
+			if err := setFlag(f, "--"+name, value); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// One or more short flags. A flag that takes a value consumes the rest of the argument or, if
+			// nothing is left, the next argument.
+			shorts := arg[1:]
+			for j := 0; j < len(shorts); j++ {
+				name := shorts[j : j+1]
+				f := fs.lookup(name, true)
+				if f == nil {
+					return nil, fmt.Errorf("unknown flag: -%s", name)
+				}
+				value := "true"
+				if f.valueName != "" {
+					value = strings.TrimPrefix(shorts[j+1:], "=")
+					if value == "" {
+						if i+1 >= len(args) {
+							return nil, fmt.Errorf("missing value for -%s", name)
+						}
+						i++
+						value = args[i]
+					}
+					j = len(shorts)
+				}
+				if err := setFlag(f, "-"+name, value); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	return positional, nil
+}
+
+// PrintDefaults Prints the help message of all flags.
+func (fs *flagSet) PrintDefaults() {
+	for _, f := range fs.flags {
+		names := "--" + f.long
+		if f.short != "" {
+			names = "-" + f.short + ", " + names
+		}
+		if f.valueName != "" {
+			names += " <" + f.valueName + ">"
+		}
+		fmt.Printf("\n\t%s\n", names)
+		for _, line := range strings.Split(f.usage, "\n") {
+			fmt.Printf("\t\t%s\n", line)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFlagSetParse(t *testing.T) {
+	type values struct {
+		shuffle, oneSided bool
+		number            uint
+		category          string
+	}
+	tests := []struct {
+		args           []string
+		want           values
+		wantPositional []string
+		wantErr        string
+	}{
+		{[]string{"-so", "deck.md"}, values{shuffle: true, oneSided: true}, []string{"deck.md"}, ""},
+		{[]string{"-n5", "deck.md"}, values{number: 5}, []string{"deck.md"}, ""},
+		{[]string{"-n", "5", "deck.md"}, values{number: 5}, []string{"deck.md"}, ""},
+		{[]string{"-n=5"}, values{number: 5}, nil, ""},
+		{[]string{"--number=5", "deck.md"}, values{number: 5}, []string{"deck.md"}, ""},
+		{[]string{"--number", "5"}, values{number: 5}, nil, ""},
+		{[]string{"-son5", "deck.md"}, values{shuffle: true, oneSided: true, number: 5}, []string{"deck.md"}, ""},
+		{[]string{"--shuffle=false", "-c", "Go", "deck.md"}, values{category: "Go"}, []string{"deck.md"}, ""},
+		// Flags after positional arguments.
+		{[]string{"a.md", "-s", "b.md", "--number", "3"}, values{shuffle: true, number: 3}, []string{"a.md", "b.md"},
+			""},
+		// All arguments after -- are positional.
+		{[]string{"-s", "--", "-o", "--number=5"}, values{shuffle: true}, []string{"-o", "--number=5"}, ""},
+		{[]string{"-"}, values{}, []string{"-"}, ""},
+		{[]string{"deck.md", "-n"}, values{}, nil, "missing value for -n"},
+		{[]string{"--category"}, values{}, nil, "missing value for --category"},
+		{[]string{"-n", "x"}, values{}, nil, `invalid value "x" for -n: must be a non-negative number`},
+		{[]string{"--shuffle=maybe"}, values{}, nil, `invalid value "maybe" for --shuffle: must be true or false`},
+		{[]string{"-sx"}, values{}, nil, "unknown flag: -x"},
+		// Long flags need their full name.
+		{[]string{"--n=5"}, values{}, nil, "unknown flag: --n"},
+	}
+	for _, tt := range tests {
+		var got values
+		fs := &flagSet{}
+		fs.Bool(&got.shuffle, "shuffle", "s", "")
+		fs.Bool(&got.oneSided, "one-sided", "o", "")
+		fs.Uint(&got.number, "number", "n", "number", "")
+		fs.String(&got.category, "category", "c", "category", "")
+		positional, err := fs.Parse(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.args, err)
+			continue
+		}
+		if got != tt.want || !slices.Equal(positional, tt.wantPositional) {
+			t.Errorf("Parse(%q) = %+v, %q, want %+v, %q", tt.args, got, positional, tt.want, tt.wantPositional)
+		}
+	}
+}
@@ -1,43 +1,164 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/bttger/markdown-flashcards/internal"
 )
 
+// command is a subcommand of mdfc.
+type command struct {
+	name string
+	// Arguments of the command in the usage line.
+	args        string
+	summary     string
+	description string
+	// setup registers the command's flags and returns the function that runs the command with the positional
+	// arguments.
+	setup func(fs *flagSet) func(args []string) error
+}
+
+// usageError is returned by a command if it was called with invalid arguments. The command's help is printed.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// Exit codes of mdfc, so that scripts can tell the kinds of errors apart.
+const (
+	exitError   = 1
+	exitUsage   = 2
+	exitParse   = 3
+	exitIO      = 4
+	exitNoCards = 5
+	exitLocked  = 6
+)
+
+// exitCode Returns the exit code for the error.
+func exitCode(err error) int {
+	var usageErr usageError
+	switch {
+	case errors.Is(err, internal.ErrParse):
+		return exitParse
+	case errors.Is(err, internal.ErrIO):
+		return exitIO
+	case errors.Is(err, internal.ErrNoCards):
+		return exitNoCards
+	case errors.Is(err, internal.ErrLocked):
+		return exitLocked
+	case errors.As(err, &usageErr):
+		return exitUsage
+	}
+	return exitError
+}
+
+// defaultCommand is run if the first argument is not the name of a command, e.g. `mdfc -o file.md`.
+const defaultCommand = "study"
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		studyCommand,
+		testCommand,
+		listCommand,
+		dueCommand,
+		statsCommand,
+		lintCommand,
+		reidCommand,
+		importCommand,
+		exportCommand,
+		shareCommand,
+		{
+			name:    "completion",
+			args:    "<bash|zsh|fish>",
+			summary: "Generate a shell completion script.",
+			description: "Prints a completion script for the given shell to standard output. For example, add\n" +
+				"`source <(mdfc completion bash)` to your ~/.bashrc, or run\n" +
+				"`mdfc completion fish > ~/.config/fish/completions/mdfc.fish`.",
+			setup: func(fs *flagSet) func(args []string) error {
+				return func(args []string) error {
+					if len(args) != 1 {
+						return usageError{errors.New("specify exactly one shell")}
+					}
+					return printCompletion(args[0])
+				}
+			},
+		},
+		{
+			name:    "help",
+			args:    "[command]",
+			summary: "Show the help of mdfc or of a command.",
+			setup: func(fs *flagSet) func(args []string) error {
+				return func(args []string) error {
+					if len(args) == 0 {
+						printHelp()
+						return nil
+					}
+					cmd := findCommand(args[0])
+					if cmd == nil {
+						return usageError{fmt.Errorf("unknown command: %s", args[0])}
+					}
+					printCommandHelp(cmd)
+					return nil
+				}
+			},
+		},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// newFlagSet Returns the flag set of the command and the function that runs it.
+func (c *command) newFlagSet(help *bool) (*flagSet, func(args []string) error) {
+	fs := &flagSet{}
+	fs.Bool(help, "help", "h", "Show this help message and exit.")
+	run := c.setup(fs)
+	return fs, run
+}
+
 func printHelp() {
-	fmt.Println("Usage: mdfc [options] [file]")
+	fmt.Println("Usage: mdfc <command> [options] [file|directory|glob...]")
+	fmt.Println("\nStudy flashcards written in markdown files through spaced repetition. If no command is given,")
+	fmt.Printf("the %s command is run.\n", defaultCommand)
+	fmt.Println("\nCommands:")
+	fmt.Println()
+	for _, c := range commands {
+		fmt.Printf("\t%-12s%s\n", c.name, c.summary)
+	}
+	fmt.Println("\nRun 'mdfc help <command>' for more information on a command.")
+}
+
+func printCommandHelp(c *command) {
+	var help bool
+	fs, _ := c.newFlagSet(&help)
+	fmt.Printf("Usage: mdfc %s [options] %s\n", c.name, c.args)
+	fmt.Println()
+	description := c.description
+	if description == "" {
+		description = c.summary
+	}
+	fmt.Println(description)
 	fmt.Println("\nOptions:")
-	fmt.Println("\n\t-h, --help")
-	fmt.Println("\t\tShow this help message and exit.")
-	fmt.Println("\n\t-s, --sequential")
-	fmt.Println("\t\tShow flashcards in sequential order as in the markdown file. The default behavior is to")
-	fmt.Println("\t\tshow flashcards in random order.")
-	fmt.Println("\n\t-o, --show-category")
-	fmt.Println("\t\tShow the category of each flashcard.")
-	fmt.Println("\n\t-c, --category <category>")
-	fmt.Println("\t\tShow only flashcards of the specified category. A category is a first-level heading in the")
-	fmt.Println("\t\tmarkdown file. A category can be specified by a case-insensitive prefix of the heading.")
-	fmt.Println("\t\tIf no category is specified, you can interactively choose one.")
-	fmt.Println("\n\t-t, --test <number_flashcards>")
-	fmt.Println("\t\tTest yourself in test mode with random flashcards. If no number is specified, all")
-	fmt.Println("\t\tflashcards will be shown. Possible to combine with -c, --category.")
-	fmt.Println("\n\t-n, --number <number_flashcards>")
-	fmt.Println("\t\tLearn n cards during the session. Set it to 0 to study all cards that are due to today.")
-	fmt.Println("\t\tDefaults to 20.")
-	fmt.Println("\n\t-f, --future-days-due <days>")
-	fmt.Println("\t\tUsually a flashcard is due on a particular date. If you want to learn flashcards")
-	fmt.Println("\t\tbefore they are due, you can specify the number of days in the future when a flashcard")
-	fmt.Println("\t\tshould be due. This might be helpful in the case when you have no cards due for today's")
-	fmt.Println("\t\tlearning session. Cards where the due date was missed will be added anyway. Defaults to 0.")
-	fmt.Println("\n\t-w, --wrap-lines <line_length>")
-	fmt.Println("\t\tWrap lines to a maximum length. Only breaks lines at whitespaces. Defaults to terminal width.")
-	fmt.Println("\n\t--share-file")
-	fmt.Println("\t\tCreates a copy of the flashcard file with the suffix '.share.md'. This file resets the")
-	fmt.Println("\t\tlearning progress of all flashcards. This is useful if you want to share your flashcards.")
+	fs.PrintDefaults()
 }
 
 func printDebugHelp(session internal.Session) {
@@ -46,104 +167,104 @@ func printDebugHelp(session internal.Session) {
 	}
 }
 
-const defaultNumberCards = 20
+// legacyFlags are the flags of mdfc before it had commands, with the commands that replace them. They are still
+// accepted if no command is given, with a warning.
+var legacyFlags = map[string]string{"-t": "test", "--test": "test", "--share-file": "share"}
 
-func main() {
-	args := os.Args[1:]
-	session := internal.Session{NumberCards: defaultNumberCards}
-	filePath := ""
-	createCopyToShare := false
-
-	readOptArg := false
-	for i, arg := range args {
-		switch arg {
-		case "-h", "--help":
-			printHelp()
-			return
-		case "-s", "--sequential":
-			session.Sequential = true
-		case "-o", "--show-category":
-			session.ShowCategory = true
-		case "-c", "--category":
-			session.ChooseCategories = true
-			readOptArg = true
-		case "-t", "--test":
-			session.TestMode = true
-			session.NumberCards = 0
-			readOptArg = true
-		case "-n", "--number":
-			session.NumberCards = defaultNumberCards
-			readOptArg = true
-		case "-f", "--future-days-due":
-			session.FutureDaysDue = 0
-			readOptArg = true
-		case "-w", "--wrap-lines":
-			session.WrapLines = 0
-			readOptArg = true
-		case "--share-file":
-			createCopyToShare = true
-		default:
-			if readOptArg && i != len(args)-1 {
-				switch args[i-1] {
-				case "-c", "--category":
-					session.ChooseCategories = false
-					session.Category = arg
-				case "-n", "--number", "-t", "--test":
-					n, err := strconv.Atoi(arg)
-					if err != nil || n < 0 {
-						fmt.Println("Invalid number of flashcards specified.")
-						return
-					}
-					session.NumberCards = uint(n)
-				case "-f", "--future-days-due":
-					n, err := strconv.Atoi(arg)
-					if err != nil || n < 0 {
-						fmt.Println("Invalid number of future due days specified.")
-						return
-					}
-					session.FutureDaysDue = uint(n)
-				case "-w", "--wrap-lines":
-					n, err := strconv.Atoi(arg)
-					if err != nil || n < 0 {
-						fmt.Println("Invalid number of maximum line length specified.")
-						return
-					}
-					session.WrapLines = uint(n)
-				}
-				readOptArg = false
-			} else {
-				filePath = arg
+// parseCommand Returns the command of the arguments and the arguments of the command. If the first argument is not
+// the name of a command, the default command is run, unless the argument isn't a file either. Flags of mdfc before it
+// had commands are rewritten to their replacements, and a warning is returned for each of them:
+//   - `-t [number]` and `--test [number]` run the test command (with `-n number`),
+//   - `--share-file` runs the share command,
+//   - `-c file` without a category lets the user choose the category (`-C file`).
+func parseCommand(args []string) (cmd *command, rest []string, warnings []string, err error) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if cmd = findCommand(args[0]); cmd != nil {
+			return cmd, args[1:], nil, nil
+		}
+		if _, statErr := os.Stat(args[0]); statErr != nil && !strings.ContainsAny(args[0], `*?[/\.`) {
+			return nil, nil, nil, usageError{fmt.Errorf("unknown command: %s", args[0])}
+		}
+	}
+	cmd = findCommand(defaultCommand)
+	for i := 0; i < len(args); i++ {
+		name, ok := legacyFlags[args[i]]
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+		if cmd.name != defaultCommand && cmd.name != name {
+			return cmd, nil, warnings, usageError{fmt.Errorf("%s can't be combined with 'mdfc %s'", args[i], cmd.name)}
+		}
+		cmd = findCommand(name)
+		warnings = append(warnings, fmt.Sprintf("%s is deprecated, use 'mdfc %s' instead", args[i], name))
+		// The number of cards of the test mode is optional. As before, the last argument is always the file.
+		if name == "test" && i+2 < len(args) {
+			if _, err := strconv.ParseUint(args[i+1], 10, 32); err == nil {
+				rest = append(rest, "-n", args[i+1])
+				i++
 			}
 		}
 	}
 
-	if createCopyToShare {
-		err := internal.CreateCopyToShare(filePath)
-		if err != nil {
-			fmt.Printf("%v\n\n", err)
-			printHelp()
-			return
+	// The category of -c was optional, and the last argument was always the file. The category is only taken as
+	// the file if no other file is given.
+	if n := len(rest); n >= 2 && (rest[n-2] == "-c" || rest[n-2] == "--category") {
+		var help bool
+		fs, _ := cmd.newFlagSet(&help)
+		if fs.lookup("choose-category", false) != nil {
+			if positional, err := fs.Parse(rest[:n-2]); err == nil && len(positional) == 0 {
+				warnings = append(warnings, fmt.Sprintf("%s without a category is deprecated, use -C, "+
+					"--choose-category instead", rest[n-2]))
+				rest = append(rest[:n-2:n-2], "-C", rest[n-1])
+			}
 		}
-		return
 	}
+	return cmd, rest, warnings, nil
+}
 
-	err := session.OpenFile(filePath)
-	if err != nil {
-		fmt.Printf("%v\n\n", err)
+// run Runs the command of the arguments and returns the command that was run, or nil if there is none.
+func run(args []string) (*command, error) {
+	if len(args) == 1 && (args[0] == "-h" || args[0] == "--help") {
 		printHelp()
-		return
+		return nil, nil
+	}
+	cmd, args, warnings, err := parseCommand(args)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if err != nil {
+		return cmd, err
 	}
 
-	err = session.CheckCategory()
+	var help bool
+	fs, runCommand := cmd.newFlagSet(&help)
+	positional, err := fs.Parse(args)
 	if err != nil {
-		fmt.Println("Invalid category specified.")
-		return
+		return cmd, usageError{err}
 	}
+	if help {
+		printCommandHelp(cmd)
+		return cmd, nil
+	}
+	return cmd, runCommand(positional)
+}
 
-	printDebugHelp(session)
-	if session.ChooseCategories && session.Category == "" {
-		session.ChooseCategory()
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		printHelp()
+		return
+	}
+	cmd, err := run(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		var usageErr usageError
+		if errors.As(err, &usageErr) && cmd != nil {
+			fmt.Fprintf(os.Stderr, "\nRun 'mdfc help %s' for usage.\n", cmd.name)
+		} else if errors.As(err, &usageErr) {
+			fmt.Fprintln(os.Stderr, "\nRun 'mdfc help' for usage.")
+		}
+		os.Exit(exitCode(err))
 	}
-	session.Start()
-	printDebugHelp(session)
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args         []string
+		wantCommand  string
+		wantArgs     []string
+		wantWarnings int
+	}{
+		{[]string{"deck.md"}, "study", []string{"deck.md"}, 0},
+		{[]string{"-s", "deck.md"}, "study", []string{"-s", "deck.md"}, 0},
+		{[]string{"test", "-t", "deck.md"}, "test", []string{"-t", "deck.md"}, 0},
+		{[]string{"list", "-c", "deck.md"}, "list", []string{"-c", "deck.md"}, 0},
+		{[]string{"study", "-c", "deck.md"}, "study", []string{"-c", "deck.md"}, 0},
+		// Flags of mdfc before it had commands.
+		{[]string{"-t", "deck.md"}, "test", []string{"deck.md"}, 1},
+		{[]string{"-t", "5", "deck.md"}, "test", []string{"-n", "5", "deck.md"}, 1},
+		{[]string{"-s", "--test", "5"}, "test", []string{"-s", "5"}, 1},
+		{[]string{"--share-file", "deck.md"}, "share", []string{"deck.md"}, 1},
+		{[]string{"-c", "deck.md"}, "study", []string{"-C", "deck.md"}, 1},
+		{[]string{"-s", "--category", "deck.md"}, "study", []string{"-s", "-C", "deck.md"}, 1},
+		{[]string{"-t", "3", "-c", "deck.md"}, "test", []string{"-n", "3", "-C", "deck.md"}, 2},
+		// -c with a category, or after the file.
+		{[]string{"-c", "Go", "deck.md"}, "study", []string{"-c", "Go", "deck.md"}, 0},
+		{[]string{"deck.md", "-c", "Go"}, "study", []string{"deck.md", "-c", "Go"}, 0},
+	}
+	for _, tt := range tests {
+		cmd, args, warnings, err := parseCommand(tt.args)
+		if err != nil {
+			t.Errorf("parseCommand(%q) error = %v", tt.args, err)
+			continue
+		}
+		if cmd.name != tt.wantCommand || !slices.Equal(args, tt.wantArgs) || len(warnings) != tt.wantWarnings {
+			t.Errorf("parseCommand(%q) = %s, %q, %q, want %s, %q and %d warnings", tt.args, cmd.name, args, warnings,
+				tt.wantCommand, tt.wantArgs, tt.wantWarnings)
+		}
+	}
+
+	if _, _, _, err := parseCommand([]string{"-t", "deck.md", "--share-file"}); exitCode(err) != exitUsage {
+		t.Errorf("-t together with --share-file returns %v, want a usage error", err)
+	}
+}
+
+func TestRunUsageErrors(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"stduy", "deck.md"}, "unknown command: stduy"},
+		{[]string{"help", "stduy"}, "unknown command: stduy"},
+		{[]string{"study", "deck.md", "-n"}, "missing value for -n"},
+		{[]string{"test", "deck.md", "-n"}, "missing value for -n"},
+		{[]string{"test", "--number"}, "missing value for --number"},
+		{[]string{"study", "-x", "deck.md"}, "unknown flag: -x"},
+	}
+	for _, tt := range tests {
+		_, err := run(tt.args)
+		if err == nil || err.Error() != tt.wantErr || exitCode(err) != exitUsage {
+			t.Errorf("run(%q) = %v with exit code %d, want %q with exit code %d", tt.args, err, exitCode(err),
+				tt.wantErr, exitUsage)
+		}
+	}
+}
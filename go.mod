@@ -5,7 +5,6 @@ go 1.19
 require (
 	github.com/matoous/go-nanoid v1.5.0
 	golang.org/x/exp v0.0.0-20221211140036-ad323defaf05
+	golang.org/x/sys v0.4.0
 	golang.org/x/term v0.4.0
 )
-
-require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,505 @@
+package internal
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Types of Anki cards as they are stored in the cards table.
+const (
+	ankiCardNew = iota
+	ankiCardLearning
+	ankiCardReview
+	ankiCardRelearning
+)
+
+// ankiModelCloze is the type of an Anki note type whose cards are cloze deletions.
+const ankiModelCloze = 1
+
+// maxHeadingLength is the number of characters above which the heading of an imported cloze card is shortened.
+const maxHeadingLength = 80
+
+// ankiModel is a note type of an Anki collection.
+type ankiModel struct {
+	Name string `json:"name"`
+	Type int    `json:"type"`
+	// Templates of the cards of a note. A card's ord is the index of its template, or the cloze number - 1.
+	Templates []struct {
+		Name string `json:"name"`
+	} `json:"tmpls"`
+}
+
+// ankiNote is a note of an Anki collection with its cards.
+type ankiNote struct {
+	id     int64
+	model  int64
+	tags   []string
+	fields []string
+	cards  []ankiCard
+}
+
+// ankiCard is a card of an Anki note with its scheduling state.
+type ankiCard struct {
+	deck int64
+	ord  int
+	// Type of the card, e.g. ankiCardReview.
+	cardType int
+	// Day of a review card relative to the creation of the collection.
+	due int64
+	// Interval of a review card in days.
+	interval int64
+}
+
+// ankiCollection is the content of an Anki collection that is needed to convert it to a deck file.
+type ankiCollection struct {
+	// Day on which the collection was created. Due dates of review cards are relative to it.
+	created time.Time
+	models  map[int64]ankiModel
+	decks   map[int64]string
+	// Notes in the order of their creation.
+	notes []ankiNote
+}
+
+// ImportSummary is the result of importing cards into a deck file.
+type ImportSummary struct {
+	Path string
+	// Number of imported cards, i.e. headings, and the number of their sub-cards with progress.
+	Cards, SubCards int
+	// Number of existing cards whose progress, front, or back side was updated, see ImportCSV.
+	Updated int
+	// Number of cards that were skipped because their front or back side is empty.
+	Skipped int
+	// Number of media files that were extracted next to the deck file.
+	Media int
+	// Problems of the deck file, see readFile.
+	Warnings []Warning
+}
+
+func (s ImportSummary) String() string {
+	text := fmt.Sprintf("Imported %d cards into %s", s.Cards, s.Path)
+	if s.Updated > 0 {
+		text += fmt.Sprintf(", updated %d cards", s.Updated)
+	}
+	if s.Skipped > 0 {
+		text += fmt.Sprintf(", skipped %d empty cards", s.Skipped)
+	}
+	if s.Media > 0 {
+		text += fmt.Sprintf(", extracted %d media files", s.Media)
+	}
+	return text
+}
+
+// ImportAnki Converts the notes of an Anki package (.apkg) to a new deck file at output. Each note becomes a card
+// under the category of its deck, or of its first tag if byTag is true. The fields are converted from html to
+// markdown, and the media files of the notes are extracted to a directory next to the deck file. The progress of the
+// cards is kept: a review card is put into the box whose interval is nearest to its interval, and its due date is
+// kept. Packages of Anki 2.1.50 and newer need to be exported with support for older Anki versions.
+func ImportAnki(path, output string, byTag bool) (ImportSummary, error) {
+	summary := ImportSummary{Path: output}
+	if _, err := os.Stat(output); err == nil {
+		return summary, &IOError{Op: "write", Path: output, Err: fs.ErrExist}
+	}
+	r, err := zip.OpenReader(path)
+	if errors.Is(err, zip.ErrFormat) {
+		return summary, &ParseError{Path: path, Message: "not an Anki package"}
+	} else if err != nil {
+		return summary, &IOError{Op: "read", Path: path, Err: err}
+	}
+	defer r.Close()
+
+	entries := make(map[string]*zip.File)
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+	if entries["collection.anki21b"] != nil && entries["collection.anki21"] == nil {
+		// The collection.anki2 of such a package only holds a note that asks to update Anki.
+		return summary, &ParseError{Path: path, Message: "the package was exported by Anki 2.1.50 or newer, " +
+			"export it again with 'Support older Anki versions' enabled"}
+	}
+	var collection *zip.File
+	for _, name := range []string{"collection.anki21", "collection.anki2"} {
+		if collection = entries[name]; collection != nil {
+			break
+		}
+	}
+	if collection == nil {
+		return summary, &ParseError{Path: path, Message: "not an Anki package, no collection found"}
+	}
+	data, err := readZipFile(collection)
+	if err != nil {
+		return summary, &IOError{Op: "read", Path: path, Err: err}
+	}
+	col, err := readAnkiCollection(data)
+	if err != nil {
+		return summary, &ParseError{Path: path, Message: "invalid Anki collection: " + err.Error()}
+	}
+
+	// The media files are numbered in the package; the media entry maps the numbers to the file names.
+	mediaFiles := make(map[string]*zip.File)
+	if f := entries["media"]; f != nil {
+		var names map[string]string
+		if data, err := readZipFile(f); err == nil && json.Unmarshal(data, &names) == nil {
+			for number, name := range names {
+				if entries[number] != nil {
+					mediaFiles[name] = entries[number]
+				}
+			}
+		}
+	}
+	mediaDir := strings.TrimSuffix(filepath.Base(output), ".md") + ".media"
+	var mediaErr error
+	media := func(src string) string {
+		f := mediaFiles[src]
+		if f == nil || strings.ContainsAny(src, `/\`) {
+			return src
+		}
+		target := filepath.Join(filepath.Dir(output), mediaDir, src)
+		if _, err := os.Stat(target); err != nil && mediaErr == nil {
+			mediaErr = extractZipFile(f, target)
+			summary.Media++
+		}
+		return mediaDir + "/" + src
+	}
+
+	// Cards by category in the order of the categories' first appearance.
+	var categories []string
+	cards := make(map[string][]string)
+	ids := make(map[string]bool)
+	for _, n := range col.notes {
+		category := col.category(n, byTag)
+		card, subCards := col.convertNote(n, media, ids)
+		if card == "" {
+			summary.Skipped++
+			continue
+		}
+		if cards[category] == nil {
+			categories = append(categories, category)
+		}
+		cards[category] = append(cards[category], card)
+		summary.Cards++
+		summary.SubCards += subCards
+	}
+	if mediaErr != nil {
+		return summary, &IOError{Op: "write", Path: filepath.Join(filepath.Dir(output), mediaDir), Err: mediaErr}
+	}
+	if summary.Cards == 0 {
+		return summary, fmt.Errorf("%w in %s", ErrNoCards, path)
+	}
+
+	var b strings.Builder
+	for i, category := range categories {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "# %s\n", category)
+		for _, card := range cards[category] {
+			fmt.Fprintf(&b, "\n%s\n", card)
+		}
+	}
+	if err = writeFileAtomic(output, []byte(b.String())); err != nil {
+		return summary, &IOError{Op: "write", Path: output, Err: err}
+	}
+	// Reading the deck adds the metadata of sub-cards that Anki doesn't have, e.g. of highlights.
+	file, err := readFile(output, true)
+	summary.Warnings = file.Warnings
+	return summary, err
+}
+
+// readZipFile Returns the uncompressed content of a file of a zip archive.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// extractZipFile Writes the content of a file of a zip archive to the target path. Missing directories are created.
+func extractZipFile(f *zip.File, target string) error {
+	data, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(target, data)
+}
+
+// readAnkiCollection Reads the note types, decks, notes, and cards of the SQLite database of an Anki collection. Only
+// the schema of Anki 2.1.49 and older is supported, which stores the note types and decks as JSON in the col table.
+func readAnkiCollection(data []byte) (*ankiCollection, error) {
+	db, err := openSQLite(data)
+	if err != nil {
+		return nil, err
+	}
+	colRows, err := db.table("col")
+	if err != nil {
+		return nil, err
+	}
+	if len(colRows) != 1 {
+		return nil, errors.New("the col table must have exactly one row")
+	}
+	// Columns of col: id, crt, mod, scm, ver, dty, usn, ls, conf, models, decks, dconf, tags
+	col := &ankiCollection{models: make(map[int64]ankiModel), decks: make(map[int64]string)}
+	created := time.Unix(colRows[0].int(1), 0)
+	col.created = time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+
+	var models map[string]ankiModel
+	var decks map[string]struct {
+		Name string `json:"name"`
+	}
+	if err = json.Unmarshal([]byte(colRows[0].text(9)), &models); err != nil || len(models) == 0 ||
+		db.hasTable("notetypes") {
+		return nil, errors.New("the schema of Anki 2.1.50 and newer is not supported, export the package with " +
+			"'Support older Anki versions' enabled")
+	}
+	if err = json.Unmarshal([]byte(colRows[0].text(10)), &decks); err != nil {
+		return nil, fmt.Errorf("invalid decks: %w", err)
+	}
+	for id, m := range models {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		col.models[n] = m
+	}
+	for id, d := range decks {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		col.decks[n] = d.Name
+	}
+
+	noteRows, err := db.table("notes")
+	if err != nil {
+		return nil, err
+	}
+	notes := make(map[int64]int)
+	// Columns of notes: id, guid, mid, mod, usn, tags, flds, sfld, csum, flags, data
+	for _, r := range noteRows {
+		n := ankiNote{id: r.rowid, model: r.int(2), tags: strings.Fields(r.text(5)),
+			fields: strings.Split(r.text(6), "\x1f")}
+		notes[n.id] = len(col.notes)
+		col.notes = append(col.notes, n)
+	}
+	cardRows, err := db.table("cards")
+	if err != nil {
+		return nil, err
+	}
+	// Columns of cards: id, nid, did, ord, mod, usn, type, queue, due, ivl, factor, reps, lapses, left, odue, odid,
+	// flags, data
+	for _, r := range cardRows {
+		i, ok := notes[r.int(1)]
+		if !ok {
+			continue
+		}
+		c := ankiCard{deck: r.int(2), ord: int(r.int(3)), cardType: int(r.int(6)), due: r.int(8), interval: r.int(9)}
+		if odid := r.int(15); odid != 0 {
+			// The card is in a filtered deck; its original deck and due day are stored separately.
+			c.deck, c.due = odid, r.int(14)
+		}
+		col.notes[i].cards = append(col.notes[i].cards, c)
+	}
+	for i := range col.notes {
+		slices.SortFunc(col.notes[i].cards, func(a, b ankiCard) int { return a.ord - b.ord })
+	}
+	return col, nil
+}
+
+// category Returns the category of the note: the name of the deck of its first card, or its first tag if byTag is
+// true and the note has tags.
+func (col *ankiCollection) category(n ankiNote, byTag bool) string {
+	if byTag && len(n.tags) > 0 {
+		return n.tags[0]
+	}
+	if len(n.cards) > 0 {
+		if name, ok := col.decks[n.cards[0].deck]; ok {
+			return name
+		}
+	}
+	return "Default"
+}
+
+// convertNote Returns the markdown of the card of a note and the number of its sub-cards with progress. The
+// metadata of each Anki card with a counterpart in mdfc is added to the heading. A basic note with a second card
+// becomes a card with the {reverse} marker, and each card of a cloze note is a cloze deletion. The card is empty if
+// its front or back side would be empty.
+func (col *ankiCollection) convertNote(n ankiNote, media func(string) string, ids map[string]bool) (string, int) {
+	fields := make([]string, 0, len(n.fields))
+	for _, f := range n.fields {
+		fields = append(fields, htmlToMarkdown(f, media))
+	}
+	model := col.models[n.model]
+
+	var front string
+	var back []string
+	variantOf := func(ord int) (string, bool) {
+		return "", ord == 0
+	}
+	if model.Type == ankiModelCloze {
+		// The heading is the first line of the text without the deletions.
+		text := fields[0]
+		first, _, _ := strings.Cut(text, "\n")
+		front = clozeRegex.ReplaceAllStringFunc(first, func(s string) string {
+			if hint := clozeRegex.FindStringSubmatch(s)[3]; hint != "" {
+				return "[" + hint + "]"
+			}
+			return "[...]"
+		})
+		if utf8.RuneCountInString(front) > maxHeadingLength {
+			front = string([]rune(front)[:maxHeadingLength-1]) + "…"
+		}
+		back = fields
+		variantOf = func(ord int) (string, bool) {
+			return "c" + strconv.Itoa(ord+1), true
+		}
+	} else if len(fields) > 0 {
+		front = strings.Join(strings.Fields(fields[0]), " ")
+		back = fields[1:]
+		if len(model.Templates) > 1 && slices.ContainsFunc(n.cards, func(c ankiCard) bool { return c.ord == 1 }) {
+			front += " {reverse}"
+			variantOf = func(ord int) (string, bool) {
+				if ord == 1 {
+					return variantReverse, true
+				}
+				return "", ord == 0
+			}
+		}
+	}
+	back = slices.DeleteFunc(back, func(f string) bool { return f == "" })
+	if strings.TrimSpace(front) == "" || len(back) == 0 {
+		return "", 0
+	}
+
+	heading := "## " + escapeHeading(front)
+	subCards := 0
+	for _, ac := range n.cards {
+		variant, ok := variantOf(ac.ord)
+		if !ok {
+			continue
+		}
+		now := time.Now()
+		c := Card{Id: newId(defaultIdLength), Variant: variant,
+			Due: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)}
+		for ids[c.Id] {
+			c.Id = newId(defaultIdLength)
+		}
+		ids[c.Id] = true
+		if ac.cardType == ankiCardReview {
+			c.Box = nearestBox(ac.interval)
+			c.Due = col.created.AddDate(0, 0, int(ac.due))
+		}
+		heading += " " + formatMetadata(&c)
+		subCards++
+	}
+	return heading + "\n\n" + escapeBlockStarts(strings.Join(back, "\n\n")), subCards
+}
+
+// escapeHeading Escapes the text of a heading so that it is read back as it is: html comments would be taken for
+// metadata, and `#` characters at the end for the closing sequence of the heading.
+func escapeHeading(text string) string {
+	text = strings.ReplaceAll(text, "<!--", `\<!--`)
+	if strings.HasSuffix(text, "#") {
+		text = text[:len(text)-1] + `\#`
+	}
+	return text
+}
+
+// nearestBox Returns the box of the default Leitner system whose interval is nearest to the given interval in days.
+// If two boxes are equally near, the lower box is returned.
+func nearestBox(interval int64) uint {
+	box := 0
+	distance := func(i int) int64 {
+		d := int64(boxIntervals[i]) - interval
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	for i := range boxIntervals {
+		if distance(i) < distance(box) {
+			box = i
+		}
+	}
+	return uint(box)
+}
+
+// Names of the Anki note types that the cards are exported as. They exist in every Anki collection.
+const (
+	ankiBasic         = "Basic"
+	ankiBasicReversed = "Basic (and reversed card)"
+	ankiCloze         = "Cloze"
+)
+
+// ExportAnki Writes the cards of the session's category as a text file that Anki can import (File > Import). Each
+// heading becomes a note in a subdeck named after its category below the deck root, or below a deck named after its
+// file if root is empty. Fronts and backs are rendered to html. The note ID of the heading's first card (see
+// Card.noteId) is the GUID of the note, so that importing the file again updates the notes instead of adding them
+// again, even if the first cloze deletion was removed. Cards with the {reverse}
+// marker are exported as reversible notes, and cards with cloze deletions or highlights as cloze notes.
+func (s *Session) ExportAnki(w io.Writer, root string) error {
+	fmt.Fprint(w, "#separator:tab\n#html:true\n#guid column:1\n#notetype column:2\n#deck column:3\n")
+	out := csv.NewWriter(w)
+	out.Comma = '\t'
+	for _, f := range s.Files {
+		deck := root
+		if deck == "" {
+			deck = strings.TrimSuffix(filepath.Base(f.Path), ".md")
+		}
+		for i := 0; i < len(f.Cards); i++ {
+			c := f.Cards[i]
+			// Sub-cards share the heading and are exported as one note.
+			reversed := false
+			for i+1 < len(f.Cards) && f.Cards[i+1].heading == c.heading {
+				i++
+				reversed = reversed || f.Cards[i].Variant == variantReverse
+			}
+			if !CompareCategory(c.Category, s.Category) {
+				continue
+			}
+			category := deck
+			if c.Category != "" {
+				category += "::" + c.Category
+			}
+
+			record := []string{c.noteId(), ankiBasic, category, inlineHTML(c.Front), markdownToHTML(c.Back)}
+			if c.Type == TypeCloze {
+				record[1] = ankiCloze
+				record[3], record[4] = markdownToHTML(ankiClozes(c.Back)), inlineHTML(c.Front)
+			} else if reversed {
+				record[1] = ankiBasicReversed
+			}
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ankiClozes Returns the back side of a card with the highlights turned into cloze deletions, since Anki only knows
+// the latter. The highlights are numbered after the last cloze number.
+func ankiClozes(back string) string {
+	last := 0
+	for _, m := range clozeRegex.FindAllStringSubmatch(back, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > last {
+			last = n
+		}
+	}
+	return mapOutsideCode(back, func(s string) string {
+		return highlightRegex.ReplaceAllStringFunc(s, func(h string) string {
+			last++
+			return fmt.Sprintf("{{c%d::%s}}", last, h[2:len(h)-2])
+		})
+	})
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAnkiPackage Writes an Anki package with the given collection and media files (by their name in the package)
+// and returns its path.
+func newTestAnkiPackage(t *testing.T, collection []byte, media map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "deck.apkg")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	files := map[string]string{"collection.anki2": string(collection)}
+	for name, content := range media {
+		files[name] = content
+	}
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestAnkiCollection Returns the SQLite database of an Anki collection that was created on 2024-01-01 with a basic,
+// a reversed, and a cloze note type and the decks Default and Spanish::Verbs.
+func newTestAnkiCollection(notes, cards [][]any) []byte {
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local).Unix()
+	models := `{"1": {"name": "Basic", "type": 0, "tmpls": [{"name": "Card 1"}]},
+		"2": {"name": "Basic (and reversed card)", "type": 0, "tmpls": [{"name": "Card 1"}, {"name": "Card 2"}]},
+		"3": {"name": "Cloze", "type": 1, "tmpls": [{"name": "Cloze"}]}}`
+	decks := `{"1": {"name": "Default"}, "10": {"name": "Spanish::Verbs"}}`
+	col := []any{nil, created, int64(0), int64(0), int64(11), int64(0), int64(0), int64(0), "{}", models, decks,
+		"{}", "{}"}
+	return newTestSQLite(testTable{"col", [][]any{col}}, testTable{"notes", notes}, testTable{"cards", cards})
+}
+
+// testAnkiNote Returns the row of a note of the notes table.
+func testAnkiNote(model int64, tags string, fields ...string) []any {
+	return []any{nil, "guid", model, int64(0), int64(0), tags, strings.Join(fields, "\x1f"), fields[0], int64(0),
+		int64(0), ""}
+}
+
+// testAnkiCard Returns the row of a card of the cards table.
+func testAnkiCard(note, deck, ord, cardType, due, interval int64) []any {
+	return []any{nil, note, deck, ord, int64(0), int64(0), cardType, cardType, due, interval, int64(2500), int64(0),
+		int64(0), int64(0), int64(0), int64(0), int64(0), ""}
+}
+
+func TestImportAnki(t *testing.T) {
+	notes := [][]any{
+		testAnkiNote(1, " networks ", "What is <b>TCP</b>?", `A <i>protocol</i>.<br><img src="tcp.png">`),
+		testAnkiNote(2, "", "hablar", "to speak"),
+		testAnkiNote(3, "", "The {{c1::cell}} has a {{c2::nucleus::organelle}}.", ""),
+		testAnkiNote(1, "", "", ""),
+	}
+	cards := [][]any{
+		testAnkiCard(1, 1, 0, ankiCardReview, 200, 9),
+		testAnkiCard(2, 10, 0, ankiCardReview, 150, 30),
+		testAnkiCard(2, 10, 1, ankiCardNew, 1, 0),
+		testAnkiCard(3, 10, 1, ankiCardLearning, 0, 0),
+		testAnkiCard(3, 10, 0, ankiCardReview, 100, 3),
+		testAnkiCard(4, 1, 0, ankiCardNew, 0, 0),
+	}
+	apkg := newTestAnkiPackage(t, newTestAnkiCollection(notes, cards),
+		map[string]string{"media": `{"0": "tcp.png"}`, "0": "png"})
+	output := filepath.Join(t.TempDir(), "imported.md")
+	summary, err := ImportAnki(apkg, output, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Cards != 3 || summary.SubCards != 5 || summary.Skipped != 1 || summary.Media != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	media := filepath.Join(filepath.Dir(output), "imported.media", "tcp.png")
+	if data, err := os.ReadFile(media); string(data) != "png" {
+		t.Errorf("media file not extracted: %v", err)
+	}
+
+	// The imported deck is valid input for readFile and keeps the progress of the review cards.
+	file, err := readFile(output, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Warnings) > 0 {
+		t.Errorf("unexpected warnings %v", file.Warnings)
+	}
+	type card struct {
+		category, front, back, variant string
+		box                            uint
+		due                            string
+	}
+	today := time.Now().Format("2006-01-02")
+	want := []card{
+		{"Default", "What is **TCP**?", "A *protocol*.\n![tcp.png](imported.media/tcp.png)", "", 4, "2024-07-19"},
+		{"Spanish::Verbs", "hablar", "to speak", "", 6, "2024-05-30"},
+		{"Spanish::Verbs", "hablar", "to speak", "r", 0, today},
+		{"Spanish::Verbs", "The [...] has a [organelle].", "The {{c1::cell}} has a {{c2::nucleus::organelle}}.", "c1",
+			2, "2024-04-10"},
+		{"Spanish::Verbs", "The [...] has a [organelle].", "The {{c1::cell}} has a {{c2::nucleus::organelle}}.", "c2",
+			0, today},
+	}
+	if len(file.Cards) != len(want) {
+		t.Fatalf("got %d cards, want %d", len(file.Cards), len(want))
+	}
+	for i, c := range file.Cards {
+		got := card{c.Category, c.Front, c.Back, c.Variant, c.Box, c.Due.Format("2006-01-02")}
+		if got != want[i] {
+			t.Errorf("card %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestImportAnkiCorrupt(t *testing.T) {
+	notes := [][]any{testAnkiNote(1, "", "Q", "A")}
+	cards := [][]any{testAnkiCard(1, 1, 0, ankiCardNew, 0, 0)}
+	collection := newTestAnkiCollection(notes, cards)
+	// The second page holds the col table. Its number of cells doesn't fit on the page.
+	binary.BigEndian.PutUint16(collection[testPageSize+3:], 0xffff)
+	apkg := newTestAnkiPackage(t, collection, nil)
+	_, err := ImportAnki(apkg, filepath.Join(t.TempDir(), "imported.md"), false)
+	if !errors.Is(err, ErrParse) || !strings.Contains(err.Error(), "invalid number of cells on page 2") {
+		t.Errorf("ImportAnki() = %v, want a parse error about the number of cells", err)
+	}
+}
+
+func TestExportAnkiGUID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, testDeck)
+	guids := func() []string {
+		t.Helper()
+		s := &Session{}
+		if err := s.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		var b strings.Builder
+		if err := s.ExportAnki(&b, ""); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+			if !strings.HasPrefix(line, "#") {
+				ids = append(ids, strings.Split(line, "\t")[0])
+			}
+		}
+		return ids
+	}
+	want := []string{"bcast001", "tcp00001", "cell0001"}
+	if got := guids(); !slices.Equal(got, want) {
+		t.Fatalf("GUIDs = %v, want %v", got, want)
+	}
+
+	// The note of the cloze card keeps its GUID after the first deletion is removed, and after a new first deletion
+	// is added.
+	data, _ := os.ReadFile(path)
+	writeTestFile(t, path, strings.Replace(string(data), "{{c1::mitochondria}}", "mitochondria", 1))
+	if got := guids(); !slices.Equal(got, want) {
+		t.Errorf("GUIDs after removing the first deletion = %v, want %v", got, want)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "<!--mdfc:2;cell0002;5;2023-03-05;v=c2;n=cell0001-->") {
+		t.Errorf("note ID isn't stored in the file:\n%s", data)
+	}
+	writeTestFile(t, path, strings.Replace(string(data), "The mitochondria", "The {{c1::mitochondria}}", 1))
+	if got := guids(); !slices.Equal(got, want) {
+		t.Errorf("GUIDs after adding a first deletion = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// answerLineRegex matches a line of the back side that holds the short answer of a card, e.g. `Answer: der Hund` or
+// `**Answer:** der Hund`.
+var answerLineRegex = regexp.MustCompile(`(?im)^\s*[*_]*answer[*_]*\s*:[*_]*\s*(.+?)\s*$`)
+
+// Minimum similarity of a typed answer to the expected answer to suggest Hard instead of NotRemembered, i.e. the
+// answer is considered to contain only typos.
+const typoSimilarity = 0.8
+
+// expectedAnswer Returns the answer a typed answer is compared to: the front side of a reverse card, the deletions of
+// a cloze card, the text of the first `Answer:` line of the back side, or the whole back side if there is none.
+func expectedAnswer(c *Card) string {
+	if c.Variant == variantReverse {
+		return c.Front
+	}
+	if c.Variant != "" {
+		return strings.Join(clozeAnswers(c.Back, c.Variant), " ")
+	}
+	back := c.Back
+	if m := answerLineRegex.FindStringSubmatch(back); m != nil {
+		return m[1]
+	}
+	return strings.TrimSpace(back)
+}
+
+// normalizeWord Returns the word in lower case and without punctuation and markdown markup.
+func normalizeWord(word string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, word)
+}
+
+// answerWords Splits the answer into words. Words that consist of punctuation or markup only are dropped.
+func answerWords(answer string) (words, normalized []string) {
+	for _, w := range strings.Fields(answer) {
+		if n := normalizeWord(w); n != "" {
+			words = append(words, w)
+			normalized = append(normalized, n)
+		}
+	}
+	return words, normalized
+}
+
+// levenshtein Returns the number of single character insertions, deletions, and substitutions to change a into b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// answerSimilarity Returns how similar the typed answer is to the expected answer, from 0 (nothing in common) to 1
+// (equal). Case, punctuation, markup, and whitespace are ignored. An empty answer has nothing in common with any
+// expected answer, even an empty one.
+func answerSimilarity(typed, expected string) float64 {
+	_, t := answerWords(typed)
+	_, e := answerWords(expected)
+	a, b := []rune(strings.Join(t, " ")), []rune(strings.Join(e, " "))
+	if len(a) == 0 {
+		return 0
+	}
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// suggestGrade Suggests how difficult a card was to remember based on the similarity of the typed answer. A correct
+// answer is suggested as Okay, since typing it doesn't tell how easy it was to recall.
+func suggestGrade(similarity float64) float32 {
+	switch {
+	case similarity == 1:
+		return Okay
+	case similarity >= typoSimilarity:
+		return Hard
+	}
+	return NotRemembered
+}
+
+// answerDiff Returns a word diff of the typed answer against the expected answer. Words that are wrong are struck
+// through in red, missing words are green. Without color, they are marked as [-wrong-] and {+missing+}.
+func answerDiff(typed, expected string, color bool) string {
+	r := renderer{color: color}
+	tw, tn := answerWords(typed)
+	ew, en := answerWords(expected)
+
+	// Longest common subsequence of the normalized words.
+	lcs := make([][]int, len(tn)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(en)+1)
+	}
+	for i := len(tn) - 1; i >= 0; i-- {
+		for j := len(en) - 1; j >= 0; j-- {
+			if tn[i] == en[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	wrong := func(w string) string {
+		if color {
+			return r.style(ansiRed+";"+ansiStrike, w)
+		}
+		return "[-" + w + "-]"
+	}
+	missing := func(w string) string {
+		if color {
+			return r.style(ansiGreen, w)
+		}
+		return "{+" + w + "+}"
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(tn) || j < len(en) {
+		switch {
+		case i < len(tn) && j < len(en) && tn[i] == en[j]:
+			out = append(out, tw[i])
+			i++
+			j++
+		case j == len(en) || (i < len(tn) && lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, wrong(tw[i]))
+			i++
+		default:
+			out = append(out, missing(ew[j]))
+			j++
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// gradeLabel Returns the label of the difficulty as it is shown to the user.
+func gradeLabel(difficulty float32) string {
+	switch difficulty {
+	case Hard:
+		return "Hard"
+	case Okay:
+		return "Okay"
+	case Easy:
+		return "Easy"
+	}
+	return "Not remembered"
+}
+
+// verdictOf Returns the verdict on a checked answer for which the given grade is suggested.
+func verdictOf(r renderer, suggestion float32) string {
+	switch suggestion {
+	case Okay:
+		return r.style(ansiGreen, "Correct!")
+	case Hard:
+		return r.style(ansiYellow, "Almost correct.")
+	}
+	return r.style(ansiRed, "Wrong.")
+}
+
+// answerFeedback Returns the comparison of the typed answer to the card, i.e. the word diff wrapped to lineLength,
+// and the suggested grade.
+func answerFeedback(typed string, c *Card, lineLength uint, color bool) (feedback string, suggestion float32) {
+	expected := expectedAnswer(c)
+	similarity := answerSimilarity(typed, expected)
+	suggestion = suggestGrade(similarity)
+	r := newRenderer(lineLength, color)
+	verdict := verdictOf(r, suggestion)
+	if strings.TrimSpace(typed) == "" {
+		verdict += " No answer was given."
+	} else {
+		verdict += fmt.Sprintf(" %d%% match:\n", int(similarity*100))
+		verdict += strings.Join(r.wrap(answerDiff(typed, expected, color), "", ""), "\n")
+	}
+	return fmt.Sprintf("%s\nSuggested grade: %s\n", verdict, gradeLabel(suggestion)), suggestion
+}
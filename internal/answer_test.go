@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnswerSimilarity(t *testing.T) {
+	tests := []struct {
+		typed, expected string
+		want            float64
+		wantGrade       float32
+	}{
+		{"der Hund", "der Hund", 1, Okay},
+		// Case, punctuation, markup, and whitespace are ignored.
+		{"  Der   HUND! ", "**der Hund**.", 1, Okay},
+		{"der Hnud", "der Hund", 0.75, NotRemembered},
+		{"der Hunt", "der Hund", 0.875, Hard},
+		{"die Katze", "der Hund", 1 - 7.0/9, NotRemembered},
+		{"", "der Hund", 0, NotRemembered},
+		{"...", "der Hund", 0, NotRemembered},
+		// An empty answer isn't correct, even if nothing is expected.
+		{"", "", 0, NotRemembered},
+		{"", "**", 0, NotRemembered},
+		{"der Hund", "", 0, NotRemembered},
+		// Characters are compared, not bytes.
+		{"Straße", "strasse", 1 - 2.0/7, NotRemembered},
+		{"Übung", "übung", 1, Okay},
+		{"東京", "東京", 1, Okay},
+		{"東京都", "東京", 1 - 1.0/3, NotRemembered},
+		{"naïve", "naive", 0.8, Hard},
+	}
+	for _, tt := range tests {
+		got := answerSimilarity(tt.typed, tt.expected)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("answerSimilarity(%q, %q) = %v, want %v", tt.typed, tt.expected, got, tt.want)
+		}
+		if grade := suggestGrade(got); grade != tt.wantGrade {
+			t.Errorf("suggestGrade() of %q for %q = %v, want %v", tt.typed, tt.expected, grade, tt.wantGrade)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"größe", "grösse", 2},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein([]rune(tt.b), []rune(tt.a)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestAnswerDiff(t *testing.T) {
+	tests := []struct {
+		typed, expected, want string
+	}{
+		{"der Hund", "Der Hund.", "der Hund"},
+		{"die Hund", "der Hund", "[-die-] {+der+} Hund"},
+		{"the big dog", "the dog", "the [-big-] dog"},
+		{"the dog", "the big brown dog", "the {+big+} {+brown+} dog"},
+		{"", "der Hund", "{+der+} {+Hund+}"},
+		{"der Hund", "", "[-der-] [-Hund-]"},
+		{"Übung macht", "übung macht den Meister", "Übung macht {+den+} {+Meister+}"},
+		// Words that consist of punctuation only are dropped.
+		{"a - b", "a b", "a b"},
+	}
+	for _, tt := range tests {
+		if got := answerDiff(tt.typed, tt.expected, false); got != tt.want {
+			t.Errorf("answerDiff(%q, %q) = %q, want %q", tt.typed, tt.expected, got, tt.want)
+		}
+	}
+
+	// With color, wrong words are struck through in red and missing words are green.
+	got := answerDiff("die Hund", "der Hund", true)
+	want := "\x1b[31;9mdie\x1b[0m \x1b[32mder\x1b[0m Hund"
+	if got != want {
+		t.Errorf("answerDiff() with color = %q, want %q", got, want)
+	}
+}
+
+func TestAnswerFeedback(t *testing.T) {
+	c := &Card{Front: "dog", Back: "Some notes.\n\n**Answer:** der Hund"}
+	tests := []struct {
+		typed          string
+		wantSuggestion float32
+		wantFeedback   string
+	}{
+		{"der Hund", Okay, "Correct! 100% match:\nder Hund\nSuggested grade: Okay\n"},
+		{"der Hunt", Hard, "Almost correct. 87% match:\nder [-Hunt-] {+Hund+}\nSuggested grade: Hard\n"},
+		{"  ", NotRemembered, "Wrong. No answer was given.\nSuggested grade: Not remembered\n"},
+	}
+	for _, tt := range tests {
+		feedback, suggestion := answerFeedback(tt.typed, c, 80, false)
+		if feedback != tt.wantFeedback || suggestion != tt.wantSuggestion {
+			t.Errorf("answerFeedback(%q) = %q, %v, want %q, %v", tt.typed, feedback, suggestion, tt.wantFeedback,
+				tt.wantSuggestion)
+		}
+	}
+
+	// A card without an expected answer is never answered correctly.
+	empty := &Card{Front: "Q", Back: "  "}
+	if _, suggestion := answerFeedback("", empty, 80, false); suggestion != NotRemembered {
+		t.Errorf("empty answer to an empty back side is suggested as %v", suggestion)
+	}
+	if got := expectedAnswer(&Card{Front: "Hund", Back: "dog", Variant: variantReverse}); got != "Hund" {
+		t.Error("the expected answer of a reverse card isn't its front side")
+	}
+}
@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// CardType is the type of a card. The answers of multiple choice, true/false and order cards are graded
+// automatically.
+type CardType int
+
+const (
+	TypeBasic CardType = iota
+	TypeCloze
+	TypeMultipleChoice
+	TypeTrueFalse
+	TypeOrder
+	numberCardTypes
+)
+
+var cardTypeNames = [numberCardTypes]string{"Basic", "Cloze", "Multiple choice", "True/false", "Order"}
+
+func (t CardType) String() string {
+	return cardTypeNames[t]
+}
+
+// autoGraded Returns true if the answers of cards of this type are graded automatically.
+func (t CardType) autoGraded() bool {
+	return t == TypeMultipleChoice || t == TypeTrueFalse || t == TypeOrder
+}
+
+var (
+	// choiceRegex matches an option of a multiple choice card: a task list item that is checked if it is correct.
+	choiceRegex = regexp.MustCompile(`^\s*[-+*]\s+\[([ xX])\]\s+(.*)$`)
+	// orderItemRegex matches an item of an order card: an item of an ordered list.
+	orderItemRegex = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	// orderRegex matches the marker of an order card in its heading.
+	orderRegex = regexp.MustCompile(`\s*\{order\}`)
+)
+
+// choiceOption is an option of an automatically graded card.
+type choiceOption struct {
+	text    string
+	correct bool
+	// Position of the item in the correct order of an order card.
+	position int
+}
+
+// cardType Returns the type of the card with the given heading and back side.
+//   - A cloze card has cloze deletions in its back side (see clozeVariants).
+//   - An order card has the {order} marker in its heading and an ordered list with at least two items in the correct
+//     order.
+//   - A multiple choice card has a task list with at least two options, of which the correct ones are checked.
+//   - A true/false card has a back side that starts with a line saying `True` or `False`.
+func cardType(heading, back string) CardType {
+	if clozeVariants(back) != nil {
+		return TypeCloze
+	}
+	if orderRegex.MatchString(metadataRegex.ReplaceAllString(heading, "")) {
+		if options, _ := parseChoices(TypeOrder, back); len(options) >= 2 {
+			return TypeOrder
+		}
+	}
+	options, _ := parseChoices(TypeMultipleChoice, back)
+	for _, o := range options {
+		if o.correct && len(options) >= 2 {
+			return TypeMultipleChoice
+		}
+	}
+	if options, _ := parseChoices(TypeTrueFalse, back); options != nil {
+		return TypeTrueFalse
+	}
+	return TypeBasic
+}
+
+// parseChoices Returns the options of an automatically graded card of the given type and the rest of the back side,
+// which explains the answer.
+func parseChoices(t CardType, back string) (options []choiceOption, explanation string) {
+	if t == TypeTrueFalse {
+		first, explanation, _ := strings.Cut(strings.TrimSpace(back), "\n")
+		answer := normalizeWord(first)
+		if answer != "true" && answer != "false" {
+			return nil, ""
+		}
+		return []choiceOption{{text: "True", correct: answer == "true"}, {text: "False", correct: answer == "false"}},
+			strings.TrimSpace(explanation)
+	}
+
+	var rest []string
+	for _, l := range strings.Split(back, "\n") {
+		if m := choiceRegex.FindStringSubmatch(l); t == TypeMultipleChoice && m != nil {
+			options = append(options, choiceOption{text: m[2], correct: m[1] != " "})
+		} else if m := orderItemRegex.FindStringSubmatch(l); t == TypeOrder && m != nil {
+			options = append(options, choiceOption{text: m[1], position: len(options)})
+		} else {
+			rest = append(rest, l)
+		}
+	}
+	return options, strings.TrimSpace(strings.Join(rest, "\n"))
+}
+
+// choiceQuestion is the state of an automatically graded card while it is shown.
+type choiceQuestion struct {
+	card *Card
+	// Options in the order they are shown.
+	options     []choiceOption
+	explanation string
+	// Indices of the selected options in the order of selection.
+	selected []int
+}
+
+// newChoiceQuestion Returns the question of an automatically graded card. The options of multiple choice and order
+// cards are shuffled.
+func newChoiceQuestion(c *Card) *choiceQuestion {
+	options, explanation := parseChoices(c.Type, c.Back)
+	if c.Type != TypeTrueFalse {
+		rand.Shuffle(len(options), func(i, j int) {
+			options[i], options[j] = options[j], options[i]
+		})
+	}
+	return &choiceQuestion{card: c, options: options, explanation: explanation}
+}
+
+// key Returns the key that selects the option with the given index.
+func (q *choiceQuestion) key(i int) rune {
+	if q.card.Type == TypeTrueFalse {
+		return []rune(strings.ToLower(q.options[i].text))[0]
+	}
+	return rune('a' + i)
+}
+
+// optionOf Returns the index of the option that is selected by the key, or -1.
+func (q *choiceQuestion) optionOf(key rune) int {
+	for i := range q.options {
+		if q.key(i) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *choiceQuestion) isSelected(i int) bool {
+	return slices.Contains(q.selected, i)
+}
+
+// press Handles a key press while the question is shown. It returns true if the answer is complete and should be
+// graded right away.
+func (q *choiceQuestion) press(key rune) (submit bool) {
+	i := q.optionOf(key)
+	if i == -1 {
+		return false
+	}
+	switch q.card.Type {
+	case TypeTrueFalse:
+		q.selected = []int{i}
+		return true
+	case TypeMultipleChoice:
+		// Toggle the option.
+		if j := slices.Index(q.selected, i); j != -1 {
+			q.selected = slices.Delete(q.selected, j, j+1)
+			return false
+		}
+	case TypeOrder:
+		if q.isSelected(i) {
+			return false
+		}
+	}
+	q.selected = append(q.selected, i)
+	return false
+}
+
+// removeLast Removes the last selected option.
+func (q *choiceQuestion) removeLast() {
+	if len(q.selected) > 0 {
+		q.selected = q.selected[:len(q.selected)-1]
+	}
+}
+
+// complete Returns true if the answer can be graded: an order card needs all items in order, the other types at
+// least one option.
+func (q *choiceQuestion) complete() bool {
+	if q.card.Type == TypeOrder {
+		return len(q.selected) == len(q.options)
+	}
+	return len(q.selected) > 0
+}
+
+// parse Parses an answer typed in the line-based interface, e.g. `ac` or `a, c`. It returns false if the answer is
+// invalid.
+func (q *choiceQuestion) parse(input string) bool {
+	q.selected = nil
+	input = strings.ToLower(strings.TrimSpace(input))
+	if q.card.Type == TypeTrueFalse {
+		for i, o := range q.options {
+			if input == strings.ToLower(o.text) {
+				q.press(q.key(i))
+				return true
+			}
+		}
+	}
+	for _, r := range input {
+		if r == ' ' || r == ',' {
+			continue
+		}
+		i := q.optionOf(r)
+		if i == -1 {
+			q.selected = nil
+			return false
+		}
+		if !q.isSelected(i) {
+			q.press(r)
+		}
+	}
+	return q.complete()
+}
+
+// correctCount Returns the number of correct options.
+func (q *choiceQuestion) correctCount() (n int) {
+	for _, o := range q.options {
+		if o.correct {
+			n++
+		}
+	}
+	return n
+}
+
+// instructions Returns how to answer the question in the line-based interface.
+func (q *choiceQuestion) instructions() string {
+	switch q.card.Type {
+	case TypeTrueFalse:
+		return "Enter 't' for true or 'f' for false."
+	case TypeOrder:
+		return "Enter the letters in the correct order, e.g. 'cab'."
+	}
+	if n := q.correctCount(); n > 1 {
+		return fmt.Sprintf("Enter the letters of the %d correct options, e.g. 'ac'.", n)
+	}
+	return "Enter the letter of the correct option."
+}
+
+// keyHints Returns the key hints of the full-screen interface while the question is shown.
+func (q *choiceQuestion) keyHints() string {
+	last := string(q.key(len(q.options) - 1))
+	switch q.card.Type {
+	case TypeTrueFalse:
+		return "t: true  f: false"
+	case TypeOrder:
+		return "a-" + last + ": next item  backspace: remove  enter: check"
+	}
+	return "a-" + last + ": select  enter: check"
+}
+
+// question Returns the markdown of the front side with the options and the current selection.
+func (q *choiceQuestion) question() string {
+	var b strings.Builder
+	b.WriteString(q.card.Front)
+	b.WriteString("\n\n")
+	switch q.card.Type {
+	case TypeTrueFalse:
+		b.WriteString("True or false?\n")
+	case TypeMultipleChoice:
+		if n := q.correctCount(); n > 1 {
+			fmt.Fprintf(&b, "Choose %d options:\n\n", n)
+		}
+		for i, o := range q.options {
+			box := " "
+			if q.isSelected(i) {
+				box = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %c) %s\n", box, q.key(i), o.text)
+		}
+	case TypeOrder:
+		b.WriteString("Put the items in the correct order:\n\n")
+		for i, o := range q.options {
+			fmt.Fprintf(&b, "- %c) %s\n", q.key(i), o.text)
+		}
+		if len(q.selected) > 0 {
+			keys := make([]string, len(q.selected))
+			for i, s := range q.selected {
+				keys[i] = string(q.key(s))
+			}
+			fmt.Fprintf(&b, "\nYour order: %s\n", strings.Join(keys, ", "))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// feedback Grades the selected options and returns the result wrapped to lineLength and the suggested grade.
+func (q *choiceQuestion) feedback(lineLength uint, color bool) (feedback string, suggestion float32) {
+	result, suggestion := q.grade()
+	return fmt.Sprintf("%s\n%sSuggested grade: %s\n", verdictOf(newRenderer(lineLength, color), suggestion),
+		RenderMarkdown(result, lineLength, color), gradeLabel(suggestion)), suggestion
+}
+
+// grade Grades the selected options and returns the markdown of the result and the suggested difficulty.
+//   - Multiple choice: Okay if all options are right, Hard if one option of several correct ones is wrong or missed,
+//     and NotRemembered otherwise.
+//   - True/false: Okay or NotRemembered.
+//   - Order: Okay if all items are in the correct position, Hard if two items of at least four are swapped, and
+//     NotRemembered otherwise.
+func (q *choiceQuestion) grade() (result string, suggestion float32) {
+	var b strings.Builder
+	mistakes := 0
+	switch q.card.Type {
+	case TypeTrueFalse, TypeMultipleChoice:
+		for i, o := range q.options {
+			selected := q.isSelected(i)
+			box, text, mark := " ", o.text, ""
+			if selected {
+				box = "x"
+			}
+			switch {
+			case selected && o.correct:
+				text, mark = "**"+o.text+"**", " ✓"
+			case selected:
+				text, mark = "~~"+o.text+"~~", " ✗"
+				mistakes++
+			case o.correct:
+				text, mark = "**"+o.text+"**", " ✗ (missed)"
+				mistakes++
+			}
+			fmt.Fprintf(&b, "- [%s] %c) %s%s\n", box, q.key(i), text, mark)
+		}
+	case TypeOrder:
+		for n, s := range q.selected {
+			o := q.options[s]
+			mark := " ✓"
+			if o.position != n {
+				mark = " ✗"
+				mistakes++
+			}
+			fmt.Fprintf(&b, "%d. %s%s\n", n+1, o.text, mark)
+		}
+		if mistakes > 0 {
+			b.WriteString("\nThe correct order is:\n\n")
+			ordered, _ := parseChoices(TypeOrder, q.card.Back)
+			for _, o := range ordered {
+				fmt.Fprintf(&b, "%d. %s\n", o.position+1, o.text)
+			}
+		}
+	}
+
+	switch {
+	case mistakes == 0:
+		suggestion = Okay
+	case q.card.Type == TypeMultipleChoice && mistakes == 1 && q.correctCount() > 1:
+		suggestion = Hard
+	case q.card.Type == TypeOrder && mistakes == 2 && len(q.options) >= 4:
+		suggestion = Hard
+	default:
+		suggestion = NotRemembered
+	}
+	return b.String(), suggestion
+}
@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// newTestChoiceQuestion Returns the question of a card with the given type and back side. Unlike newChoiceQuestion,
+// the options are not shuffled.
+func newTestChoiceQuestion(t CardType, back string) *choiceQuestion {
+	c := &Card{Front: "Question", Back: back, Type: t}
+	options, explanation := parseChoices(t, back)
+	return &choiceQuestion{card: c, options: options, explanation: explanation}
+}
+
+func TestParseChoices(t *testing.T) {
+	tests := []struct {
+		name            string
+		t               CardType
+		back            string
+		wantOptions     []choiceOption
+		wantExplanation string
+	}{
+		{"multiple choice", TypeMultipleChoice,
+			"Pick one.\n\n- [ ] UDP\n* [x] TCP\n  + [X] **SCTP**\n\nBoth are reliable.",
+			[]choiceOption{{text: "UDP"}, {text: "TCP", correct: true}, {text: "**SCTP**", correct: true}},
+			"Pick one.\n\n\nBoth are reliable."},
+		{"no options", TypeMultipleChoice, "- a list\n- [] not an option", nil, "- a list\n- [] not an option"},
+		{"order", TypeOrder, "1. first\n2) second\n10. third\n- not an item\n\nNotes",
+			[]choiceOption{{text: "first"}, {text: "second", position: 1}, {text: "third", position: 2}},
+			"- not an item\n\nNotes"},
+		{"true", TypeTrueFalse, "\n**True.**\nThe sky is blue.\n",
+			[]choiceOption{{text: "True", correct: true}, {text: "False"}}, "The sky is blue."},
+		{"false", TypeTrueFalse, "false", []choiceOption{{text: "True"}, {text: "False", correct: true}}, ""},
+		{"not true or false", TypeTrueFalse, "True colors\nof the sky", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, explanation := parseChoices(tt.t, tt.back)
+			if !reflect.DeepEqual(options, tt.wantOptions) || explanation != tt.wantExplanation {
+				t.Errorf("parseChoices() = %+v, %q, want %+v, %q", options, explanation, tt.wantOptions,
+					tt.wantExplanation)
+			}
+		})
+	}
+}
+
+func TestCardType(t *testing.T) {
+	tests := []struct {
+		heading, back string
+		want          CardType
+	}{
+		{"## Q", "answer", TypeBasic},
+		{"## Q", "The {{c1::cell}}.", TypeCloze},
+		{"## Q", "- [x] a\n- [ ] b", TypeMultipleChoice},
+		// A multiple choice card needs a correct option and at least two options.
+		{"## Q", "- [ ] a\n- [ ] b", TypeBasic},
+		{"## Q", "- [x] a", TypeBasic},
+		{"## Q {order} <!--mdfc:2;abcd1234;0;2023-03-01-->", "1. a\n2. b", TypeOrder},
+		{"## Q {order}", "1. a", TypeBasic},
+		{"## Q", "1. a\n2. b", TypeBasic},
+		{"## Q", "False\n\nExplanation", TypeTrueFalse},
+	}
+	for _, tt := range tests {
+		if got := cardType(tt.heading, tt.back); got != tt.want {
+			t.Errorf("cardType(%q, %q) = %v, want %v", tt.heading, tt.back, got, tt.want)
+		}
+	}
+}
+
+func TestChoiceGrade(t *testing.T) {
+	tests := []struct {
+		name string
+		t    CardType
+		back string
+		keys string
+		want float32
+	}{
+		{"multiple choice correct", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c", "ca", Okay},
+		{"multiple choice missed", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c", "a", Hard},
+		{"multiple choice wrong", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c", "abc", Hard},
+		{"multiple choice two mistakes", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c", "b", NotRemembered},
+		{"single choice wrong", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [ ] c", "b", NotRemembered},
+		// Pressing a key again deselects the option.
+		{"multiple choice toggled", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c", "abcb", Okay},
+		// Keys without an option are ignored.
+		{"invalid keys", TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c", "azc?", Okay},
+		{"true", TypeTrueFalse, "True", "t", Okay},
+		{"false", TypeTrueFalse, "True", "f", NotRemembered},
+		{"order correct", TypeOrder, "1. a\n2. b\n3. c\n4. d", "abcd", Okay},
+		{"order swapped", TypeOrder, "1. a\n2. b\n3. c\n4. d", "abdc", Hard},
+		// Selected items can't be selected again.
+		{"order repeated", TypeOrder, "1. a\n2. b\n3. c\n4. d", "aabcd", Okay},
+		{"order of three swapped", TypeOrder, "1. a\n2. b\n3. c", "bac", NotRemembered},
+		{"order shifted", TypeOrder, "1. a\n2. b\n3. c\n4. d", "dabc", NotRemembered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestChoiceQuestion(tt.t, tt.back)
+			for _, k := range tt.keys {
+				if q.press(k) && tt.t != TypeTrueFalse {
+					t.Errorf("press(%q) submits the answer", k)
+				}
+			}
+			if !q.complete() {
+				t.Fatalf("answer %q is not complete", tt.keys)
+			}
+			if _, got := q.grade(); got != tt.want {
+				t.Errorf("grade() of %q = %v, want %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChoiceInvalidInput(t *testing.T) {
+	q := newTestChoiceQuestion(TypeMultipleChoice, "- [x] a\n- [ ] b\n- [x] c")
+	if q.press('d') || q.press('A') || len(q.selected) != 0 || q.complete() {
+		t.Errorf("keys without an option select %v", q.selected)
+	}
+
+	tests := []struct {
+		input        string
+		valid        bool
+		wantSelected []int
+	}{
+		{"ac", true, []int{0, 2}},
+		{" A, c ", true, []int{0, 2}},
+		{"aa", true, []int{0}},
+		{"ad", false, nil},
+		{"", false, nil},
+	}
+	for _, tt := range tests {
+		if valid := q.parse(tt.input); valid != tt.valid || !slices.Equal(q.selected, tt.wantSelected) {
+			t.Errorf("parse(%q) = %v with %v selected, want %v with %v", tt.input, valid, q.selected, tt.valid,
+				tt.wantSelected)
+		}
+	}
+
+	// True/false cards also accept the words, order cards need all items.
+	tf := newTestChoiceQuestion(TypeTrueFalse, "False")
+	if !tf.parse("FALSE") || !slices.Equal(tf.selected, []int{1}) || tf.parse("x") {
+		t.Errorf("parse() of a true/false card selects %v", tf.selected)
+	}
+	order := newTestChoiceQuestion(TypeOrder, "1. a\n2. b\n3. c")
+	if order.parse("ab") || !order.parse("c, a, b") || !slices.Equal(order.selected, []int{2, 0, 1}) {
+		t.Errorf("parse() of an order card selects %v", order.selected)
+	}
+	order.removeLast()
+	if !slices.Equal(order.selected, []int{2, 0}) || order.complete() {
+		t.Errorf("removeLast() leaves %v", order.selected)
+	}
+}
+
+func TestChoiceFeedback(t *testing.T) {
+	tests := []struct {
+		name string
+		t    CardType
+		back string
+		keys string
+		want string
+	}{
+		{"order correct", TypeOrder, "1. one\n2. two\n3. three\n4. four", "abcd",
+			"Correct!\n1. one ✓\n2. two ✓\n3. three ✓\n4. four ✓\n\nSuggested grade: Okay\n"},
+		{"order swapped", TypeOrder, "1. one\n2. two\n3. three\n4. four", "bacd",
+			"Almost correct.\n1. two ✗\n2. one ✗\n3. three ✓\n4. four ✓\n\nThe correct order is:\n\n1. one\n2. two\n" +
+				"3. three\n4. four\n\nSuggested grade: Hard\n"},
+		{"order wrong", TypeOrder, "1. one\n2. two\n3. three", "cba",
+			"Wrong.\n1. three ✗\n2. two ✓\n3. one ✗\n\nThe correct order is:\n\n1. one\n2. two\n3. three\n\n" +
+				"Suggested grade: Not remembered\n"},
+		{"multiple choice missed", TypeMultipleChoice, "- [x] A\n- [ ] B\n- [x] C\n\nBecause.", "a",
+			"Almost correct.\n- [x] a) A ✓\n- [ ] b) B\n- [ ] c) C ✗ (missed)\n\n" +
+				"Suggested grade: Hard\n"},
+		{"multiple choice wrong", TypeMultipleChoice, "- [x] A\n- [ ] B", "b",
+			"Wrong.\n- [ ] a) A ✗ (missed)\n- [x] b) B ✗\n\nSuggested grade: Not remembered\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestChoiceQuestion(tt.t, tt.back)
+			for _, k := range tt.keys {
+				q.press(k)
+			}
+			if got, _ := q.feedback(80, false); got != tt.want {
+				t.Errorf("feedback() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
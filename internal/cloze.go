@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Cloze deletions in the back side of a card. Each cloze number of `{{c1::text}}` or `{{c1::text::hint}}` and each
+// `==highlight==` is a sub-card of its own. The variant of the sub-card is stored in its metadata, e.g. `v=c1` for
+// the first cloze number or `v=h5d3a6f2c` for a highlight (see highlightVariant).
+var (
+	clozeRegex     = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+	highlightRegex = regexp.MustCompile(`==(\S(?:[^=]*?\S)?)==`)
+)
+
+// mapOutsideCode Applies f to the parts of the markdown that are neither in a fenced code block nor in a code span.
+// Cloze deletions in code are shown as they are.
+func mapOutsideCode(md string, f func(string) string) string {
+	lines := strings.Split(md, "\n")
+	inFence := false
+	for i, line := range lines {
+		if fenceRegex.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, loc := range codeSpanRegex.FindAllStringIndex(line, -1) {
+			b.WriteString(f(line[last:loc[0]]))
+			b.WriteString(line[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		b.WriteString(f(line[last:]))
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// highlightVariant Returns the variant of a highlight, which is derived from its text, so that a highlight keeps its
+// metadata when other highlights are added or removed before it. Highlights with the same text are asked together.
+func highlightVariant(text string) string {
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	return fmt.Sprintf("h%08x", h.Sum32())
+}
+
+// isHighlightVariant Returns true if the variant is the one of a highlight. The first highlight variants were numbered
+// in the order of the highlights, e.g. `h2`.
+func isHighlightVariant(variant string) bool {
+	return strings.HasPrefix(variant, "h")
+}
+
+// clozeVariants Returns the variants of the sub-cards of a card's back side: the cloze numbers in ascending order,
+// followed by the highlights in the order of their first appearance. If the back side has no cloze deletions, it
+// returns nil.
+func clozeVariants(back string) (variants []string) {
+	var numbers []int
+	var highlights []string
+	mapOutsideCode(back, func(s string) string {
+		for _, m := range clozeRegex.FindAllStringSubmatch(s, -1) {
+			n, _ := strconv.Atoi(m[1])
+			if !slices.Contains(numbers, n) {
+				numbers = append(numbers, n)
+			}
+		}
+		for _, m := range highlightRegex.FindAllStringSubmatch(clozeRegex.ReplaceAllString(s, ""), -1) {
+			if v := highlightVariant(m[1]); !slices.Contains(highlights, v) {
+				highlights = append(highlights, v)
+			}
+		}
+		return s
+	})
+	slices.Sort(numbers)
+	for _, n := range numbers {
+		variants = append(variants, "c"+strconv.Itoa(n))
+	}
+	return append(variants, highlights...)
+}
+
+// replaceClozes Replaces the cloze deletions of the back side. For each deletion, replace is called with its text,
+// its hint, and whether it belongs to the given variant.
+func replaceClozes(back, variant string, replace func(text, hint string, current bool) string) string {
+	// Highlights inside a cloze are not deletions, so only the text between the clozes is searched for highlights.
+	highlights := func(s string) string {
+		return highlightRegex.ReplaceAllStringFunc(s, func(m string) string {
+			text := highlightRegex.FindStringSubmatch(m)[1]
+			return replace(text, "", highlightVariant(text) == variant)
+		})
+	}
+	return mapOutsideCode(back, func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, loc := range clozeRegex.FindAllStringIndex(s, -1) {
+			b.WriteString(highlights(s[last:loc[0]]))
+			m := clozeRegex.FindStringSubmatch(s[loc[0]:loc[1]])
+			n, _ := strconv.Atoi(m[1])
+			b.WriteString(replace(m[2], m[3], "c"+strconv.Itoa(n) == variant))
+			last = loc[1]
+		}
+		b.WriteString(highlights(s[last:]))
+		return b.String()
+	})
+}
+
+// clozeText Returns the back side with the deletions of the variant blanked out, or revealed in bold if reveal is
+// true. All other deletions are shown as plain text.
+func clozeText(back, variant string, reveal bool) string {
+	return replaceClozes(back, variant, func(text, hint string, current bool) string {
+		switch {
+		case !current:
+			return text
+		case reveal:
+			return "**" + text + "**"
+		case hint != "":
+			return "**[" + hint + "]**"
+		}
+		return "**[...]**"
+	})
+}
+
+// clozeAnswers Returns the texts of the deletions of the variant.
+func clozeAnswers(back, variant string) (answers []string) {
+	replaceClozes(back, variant, func(text, hint string, current bool) string {
+		if current {
+			answers = append(answers, text)
+		}
+		return text
+	})
+	return answers
+}
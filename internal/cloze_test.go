@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestClozeVariants(t *testing.T) {
+	tests := []struct {
+		back string
+		want []string
+	}{
+		{"no deletions", nil},
+		{"{{c2::b}} {{c1::a}} {{c2::c}}", []string{"c1", "c2"}},
+		{"==a== and ==b== and ==a==", []string{highlightVariant("a"), highlightVariant("b")}},
+		{"{{c1::==a==}} ==b== `==c==`", []string{"c1", highlightVariant("b")}},
+	}
+	for _, tt := range tests {
+		if got := clozeVariants(tt.back); !slices.Equal(got, tt.want) {
+			t.Errorf("clozeVariants(%q) = %v, want %v", tt.back, got, tt.want)
+		}
+	}
+}
+
+func TestClozeText(t *testing.T) {
+	back := "The {{c1::mitochondria::organelle}} makes ==ATP==, the ==nucleus== holds DNA."
+	tests := []struct {
+		variant string
+		reveal  bool
+		want    string
+	}{
+		{"c1", false, "The **[organelle]** makes ATP, the nucleus holds DNA."},
+		{"c1", true, "The **mitochondria** makes ATP, the nucleus holds DNA."},
+		{highlightVariant("nucleus"), false, "The mitochondria makes ATP, the **[...]** holds DNA."},
+	}
+	for _, tt := range tests {
+		if got := clozeText(back, tt.variant, tt.reveal); got != tt.want {
+			t.Errorf("clozeText(%q, %v) = %q, want %q", tt.variant, tt.reveal, got, tt.want)
+		}
+	}
+}
+
+// TestHighlightProgress checks that each highlight keeps its metadata when the back side changes.
+func TestHighlightProgress(t *testing.T) {
+	tests := []struct {
+		name          string
+		heading, back string
+		// Box of the card of each highlight text after the file was read.
+		want map[string]uint
+	}{
+		{"highlight added before",
+			"## Q <!--mdfc:2;aaaa1111;3;2023-03-01;v=" + highlightVariant("b") + "-->",
+			"==a== ==b==", map[string]uint{"a": 0, "b": 3}},
+		{"text corrected",
+			"## Q <!--mdfc:2;aaaa1111;3;2023-03-01;v=" + highlightVariant("nucleos") + "-->",
+			"==nucleus==", map[string]uint{"nucleus": 3}},
+		{"numbered variants",
+			"## Q <!--mdfc:2;aaaa1111;1;2023-03-01;v=h1--> <!--mdfc:2;bbbb2222;2;2023-03-01;v=h2-->",
+			"==a== ==b==", map[string]uint{"a": 1, "b": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "deck.md")
+			if err := os.WriteFile(path, []byte("# C\n\n"+tt.heading+"\n\n"+tt.back+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := readFile(path, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(file.Cards) != len(tt.want) {
+				t.Fatalf("got %d cards, want %d", len(file.Cards), len(tt.want))
+			}
+			for text, box := range tt.want {
+				i := slices.IndexFunc(file.Cards, func(c Card) bool { return c.Variant == highlightVariant(text) })
+				if i == -1 {
+					t.Fatalf("no card of highlight %q", text)
+				}
+				if file.Cards[i].Box != box {
+					t.Errorf("highlight %q is in box %d, want %d", text, file.Cards[i].Box, box)
+				}
+			}
+		})
+	}
+}
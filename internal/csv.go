@@ -0,0 +1,398 @@
+package internal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns are the columns of a CSV export in their order. An import finds them by the header row.
+var csvColumns = []string{"Front", "Back", "Category", "Id", "Variant", "Box", "Due"}
+
+// ExportCSV Writes the cards of the session's category as CSV (RFC 4180) with a header row, or as TSV if comma is a
+// tab. Each sub-card is a row of its own, so that its box and due date can be edited; sub-cards share their front,
+// back, and category and differ in their variant.
+func (s *Session) ExportCSV(w io.Writer, comma rune) error {
+	out := csv.NewWriter(w)
+	out.Comma = comma
+	out.UseCRLF = comma == ','
+	if err := out.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, c := range s.cards() {
+		if !CompareCategory(c.Category, s.Category) {
+			continue
+		}
+		record := []string{c.Front, c.Back, c.Category, c.Id, c.Variant, strconv.Itoa(int(c.Box)),
+			c.Due.Format("2006-01-02")}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvRow is a row of an imported CSV file. Box and Due are nil if the row doesn't specify them.
+type csvRow struct {
+	// Line number of the row, starting at 1.
+	line                  int
+	front, back, category string
+	id, variant           string
+	// Whether the file has a Variant column. Files of older versions of mdfc don't have it.
+	hasVariant bool
+	box        *uint
+	due        *time.Time
+}
+
+// ImportCSV Imports the cards of a CSV file with a header row, or of a TSV file if comma is a tab, into the deck file
+// at output. The header names the columns of csvColumns (case-insensitive); only Front is required. If the deck file
+// doesn't exist, it is created. Otherwise, rows are merged by ID: the box, due date, front, and back of existing
+// cards are updated, and rows with an unknown or empty ID are added as new cards at the end of their category, or of
+// the category Default. A row whose variant differs from the one of the existing card with its ID is an error, since
+// it belongs to another card. Rows without a front or back side are skipped.
+func ImportCSV(path, output string, comma rune) (ImportSummary, error) {
+	summary := ImportSummary{Path: output}
+	rows, err := readCSV(path, comma)
+	if err != nil {
+		return summary, err
+	}
+
+	var file File
+	var lines, endings []string
+	if _, err = os.Stat(output); err == nil {
+		lock, err := lockFile(output)
+		if err != nil {
+			return summary, err
+		}
+		defer lock.unlock()
+		if file, err = readFile(output, true); err != nil {
+			return summary, err
+		}
+		lines, endings = splitLines(file.content)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return summary, &IOError{Op: "read", Path: output, Err: err}
+	} else {
+		file.IdLength = defaultIdLength
+	}
+	_, frontMatterEnd, _ := parseFrontMatter(output, lines)
+	sections, _ := parseMarkdown(output, lines, frontMatterEnd)
+	ending := "\n"
+	if len(endings) > 0 && endings[0] != "" {
+		ending = endings[0]
+	}
+
+	cards := make(map[string]*Card)
+	ids := make(map[string]bool)
+	for i := range file.Cards {
+		cards[file.Cards[i].Id] = &file.Cards[i]
+		ids[file.Cards[i].Id] = true
+	}
+	// New back sides of the cards by the index of their heading line.
+	backs := make(map[int]string)
+	// Rows of new cards by category in the order of the categories' first appearance. Rows with the same front and
+	// back side are the sub-cards of one card.
+	var categories []string
+	added := make(map[string][][]*csvRow)
+
+	for i := range rows {
+		r := &rows[i]
+		c, ok := cards[r.id]
+		if !ok {
+			if r.front == "" || r.back == "" {
+				summary.Skipped++
+				continue
+			}
+			cardRows := added[r.category]
+			if cardRows == nil {
+				categories = append(categories, r.category)
+			}
+			j := slices.IndexFunc(cardRows, func(rows []*csvRow) bool {
+				return rows[0].front == r.front && rows[0].back == r.back
+			})
+			if j == -1 {
+				added[r.category] = append(cardRows, []*csvRow{r})
+				summary.Cards++
+			} else {
+				cardRows[j] = append(cardRows[j], r)
+			}
+			continue
+		}
+		if r.hasVariant && r.variant != c.Variant {
+			return summary, &ParseError{Path: path, Line: r.line, Message: fmt.Sprintf("variant %q of card %s "+
+				"doesn't match the variant %q in %s", r.variant, r.id, c.Variant, output)}
+		}
+
+		line := lines[c.heading]
+		if r.box != nil {
+			c.Box = *r.box
+		}
+		if r.due != nil {
+			c.Due = *r.due
+		}
+		re := regexp.MustCompile(metadataPattern(regexp.QuoteMeta(c.Id)))
+		line = re.ReplaceAllLiteralString(line, formatMetadata(c))
+		// Sub-cards share the heading and the back side; only a changed side is applied.
+		if r.front != "" && r.front != c.Front {
+			line = replaceQuestion(line, r.front)
+		}
+		changed := line != lines[c.heading]
+		lines[c.heading] = line
+		if r.back != "" && r.back != c.Back {
+			backs[c.heading] = r.back
+			changed = true
+		}
+		if changed {
+			summary.Updated++
+		}
+	}
+
+	inserts := make(map[int][]string)
+	for _, category := range categories {
+		var cardLines []string
+		for _, rows := range added[category] {
+			if len(cardLines) > 0 {
+				cardLines = append(cardLines, "")
+			}
+			cardLines = append(cardLines, newCardLines(rows, ids, file.IdLength)...)
+		}
+		at := categoryEnd(lines, sections, category)
+		if at == -1 {
+			at = len(lines)
+			cardLines = append([]string{"# " + escapeHeading(category), ""}, cardLines...)
+		}
+		if len(inserts[at]) > 0 {
+			inserts[at] = append(inserts[at], "")
+		}
+		inserts[at] = append(inserts[at], cardLines...)
+	}
+
+	// Rebuild the file with the new back sides and the new cards; all other lines are kept as they are.
+	var newLines, newEndings []string
+	add := func(line, ending string) {
+		if n := len(newEndings); n > 0 && newEndings[n-1] == "" {
+			// The last line of the file had no line ending.
+			newEndings[n-1] = ending
+		}
+		newLines = append(newLines, line)
+		newEndings = append(newEndings, ending)
+	}
+	addBlankLine := func() {
+		if n := len(newLines); n > 0 && strings.TrimSpace(newLines[n-1]) != "" {
+			add("", ending)
+		}
+	}
+	for i := 0; i <= len(lines); i++ {
+		if len(inserts[i]) > 0 {
+			addBlankLine()
+			for _, line := range inserts[i] {
+				add(line, ending)
+			}
+			if i < len(lines) {
+				add("", ending)
+			}
+		}
+		if i == len(lines) {
+			break
+		}
+		add(lines[i], endings[i])
+		back, ok := backs[i]
+		if !ok {
+			continue
+		}
+		sec := sections[slices.IndexFunc(sections, func(s section) bool { return s.line == i })]
+		// Keep the underline of a setext heading.
+		for j := i + 1; j < sec.start; j++ {
+			add(lines[j], endings[j])
+		}
+		add("", ending)
+		for _, line := range strings.Split(escapeBlockStarts(back), "\n") {
+			add(line, ending)
+		}
+		if sec.end < len(lines) {
+			add("", ending)
+		} else {
+			newEndings[len(newEndings)-1] = endings[len(endings)-1]
+		}
+		i = sec.end - 1
+	}
+
+	if err = writeFileAtomic(output, []byte(joinLines(newLines, newEndings))); err != nil {
+		return summary, &IOError{Op: "write", Path: output, Err: err}
+	}
+	// Reading the deck adds the metadata of the other sub-cards of new cards, e.g. of a reverse card.
+	file, err = readFile(output, true)
+	summary.Warnings = file.Warnings
+	return summary, err
+}
+
+// readCSV Reads the rows of a CSV file with a header row.
+func readCSV(path string, comma rune) ([]csvRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &IOError{Op: "read", Path: path, Err: err}
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, &ParseError{Path: path, Line: 1, Message: "missing header row"}
+	} else if err != nil {
+		return nil, csvError(path, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for _, column := range csvColumns {
+			if name == strings.ToLower(column) {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns["Front"]; !ok {
+		return nil, &ParseError{Path: path, Line: 1, Message: "the header row has no column 'Front'"}
+	}
+
+	var rows []csvRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, csvError(path, err)
+		}
+		line, _ := r.FieldPos(0)
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(strings.ReplaceAll(record[i], "\r\n", "\n"))
+			}
+			return ""
+		}
+		// A heading can't span several lines.
+		front := strings.ReplaceAll(field("Front"), "\n", " ")
+		_, hasVariant := columns["Variant"]
+		row := csvRow{line: line, front: front, back: field("Back"), category: field("Category"), id: field("Id"),
+			variant: field("Variant"), hasVariant: hasVariant}
+		if row.category == "" {
+			// Like the cards of the default deck of Anki, see ankiCollection.category.
+			row.category = "Default"
+		}
+		if box := field("Box"); box != "" {
+			n, err := strconv.ParseUint(box, 10, 32)
+			if err != nil {
+				return nil, &ParseError{Path: path, Line: line, Message: fmt.Sprintf("invalid box %q", box)}
+			}
+			b := uint(n)
+			row.box = &b
+		}
+		if due := field("Due"); due != "" {
+			d, err := time.Parse("2006-01-02", due)
+			if err != nil {
+				return nil, &ParseError{Path: path, Line: line,
+					Message: fmt.Sprintf("invalid due date %q, expected YYYY-MM-DD", due)}
+			}
+			row.due = &d
+		}
+		if row.id != "" && !idRegex.MatchString(row.id) {
+			return nil, &ParseError{Path: path, Line: line, Message: fmt.Sprintf("invalid card ID %q", row.id)}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// csvError Converts an error of the CSV reader to a ParseError.
+func csvError(path string, err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &ParseError{Path: path, Line: parseErr.Line, Message: parseErr.Err.Error()}
+	}
+	return &IOError{Op: "read", Path: path, Err: err}
+}
+
+// newCardLines Returns the lines of a new card from the rows of its sub-cards. The rows have the same front and back
+// side; a row of the reverse variant, or a second row of a basic card, adds the reverse card. Each row is the sub-card
+// of its variant; rows without a variant of the card are the remaining sub-cards in the order of the card's variants.
+// The ID of a row is kept if it is still unused.
+func newCardLines(rows []*csvRow, ids map[string]bool, idLength uint) []string {
+	heading := "## " + escapeHeading(rows[0].front)
+	back := escapeBlockStarts(rows[0].back)
+	reverse := slices.ContainsFunc(rows, func(r *csvRow) bool { return r.variant == variantReverse })
+	if (reverse || len(rows) > 1) && cardType(heading, back) == TypeBasic {
+		heading += " {reverse}"
+	}
+	variants := cardVariants(heading, back)
+	matched := make([]*csvRow, len(variants))
+	var unmatched []*csvRow
+	for _, r := range rows {
+		i := slices.Index(variants, r.variant)
+		if i == -1 || matched[i] != nil {
+			unmatched = append(unmatched, r)
+			continue
+		}
+		matched[i] = r
+	}
+	y, m, d := time.Now().Date()
+	for i, variant := range variants {
+		r := matched[i]
+		if r == nil {
+			if len(unmatched) == 0 {
+				// Reading the deck adds the metadata of the sub-card.
+				continue
+			}
+			r, unmatched = unmatched[0], unmatched[1:]
+		}
+		c := Card{Id: r.id, Variant: variant, Due: time.Date(y, m, d, 0, 0, 0, 0, time.UTC)}
+		for c.Id == "" || ids[c.Id] {
+			c.Id = newId(idLength)
+		}
+		ids[c.Id] = true
+		if r.box != nil {
+			c.Box = *r.box
+		}
+		if r.due != nil {
+			c.Due = *r.due
+		}
+		heading += " " + formatMetadata(&c)
+	}
+	return append([]string{heading, ""}, strings.Split(back, "\n")...)
+}
+
+// categoryEnd Returns the index of the line after the last card of the category, where new cards of the category
+// are inserted, or -1 if the file has no such category.
+func categoryEnd(lines []string, sections []section, category string) int {
+	for i, s := range sections {
+		if s.level != 1 || extractQuestion(lines[s.line]) != category {
+			continue
+		}
+		for _, next := range sections[i+1:] {
+			if next.level == 1 {
+				return next.line
+			}
+		}
+		return len(lines)
+	}
+	return -1
+}
+
+// replaceQuestion Replaces the question of a card's heading line and keeps its level, markers, and metadata.
+func replaceQuestion(line, question string) string {
+	prefix := atxHeadingRegex.FindString(line)
+	markers := markerRegex.FindAllString(metadataRegex.ReplaceAllString(line, ""), -1)
+	metadata := metadataRegex.FindAllString(line, -1)
+	line = prefix + escapeHeading(question) + strings.Join(markers, "")
+	if len(metadata) > 0 {
+		line += " " + strings.Join(metadata, " ")
+	}
+	return line
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testDeck has a basic card, a card with a reverse card, and a card with two cloze deletions, all with progress.
+const testDeck = `# Networks
+
+## What is a broadcast? <!--mdfc:2;bcast001;2;2023-03-01-->
+
+A message to all nodes.
+
+## TCP {reverse} <!--mdfc:2;tcp00001;1;2023-03-02--> <!--mdfc:2;tcp00002;3;2023-03-03;v=r-->
+
+Transmission Control Protocol
+
+# Biology
+
+## Cell <!--mdfc:2;cell0001;4;2023-03-04;v=c1--> <!--mdfc:2;cell0002;5;2023-03-05;v=c2-->
+
+The {{c1::mitochondria}} makes {{c2::ATP}}.
+`
+
+// cardKey Returns the fields of a card that a CSV file keeps.
+func cardKey(c *Card) string {
+	return strings.Join([]string{c.Category, c.Id, c.Variant, c.Front, c.Back, c.Due.Format("2006-01-02"),
+		fmt.Sprint(c.Box)}, "|")
+}
+
+func readTestCards(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := readFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for i := range file.Cards {
+		keys = append(keys, cardKey(&file.Cards[i]))
+	}
+	return keys
+}
+
+func exportTestCSV(t *testing.T, path string, comma rune) string {
+	t.Helper()
+	s := &Session{ReadOnly: true}
+	if err := s.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.ExportCSV(&buf, comma); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	for name, comma := range map[string]rune{"csv": ',', "tsv": '\t'} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			deck := filepath.Join(dir, "deck.md")
+			writeTestFile(t, deck, testDeck)
+			csvPath := filepath.Join(dir, "deck.csv")
+			writeTestFile(t, csvPath, exportTestCSV(t, deck, comma))
+
+			// Importing into a new file recreates the cards with their IDs and progress.
+			imported := filepath.Join(dir, "imported.md")
+			summary, err := ImportCSV(csvPath, imported, comma)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Cards != 3 || summary.Updated != 0 || summary.Skipped != 0 {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+			want := readTestCards(t, deck)
+			if got := readTestCards(t, imported); strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("imported cards\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+
+			// Importing into the original file changes nothing.
+			summary, err = ImportCSV(csvPath, deck, comma)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Cards != 0 || summary.Updated != 0 {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+			if data, _ := os.ReadFile(deck); string(data) != testDeck {
+				t.Errorf("deck changed to\n%s", data)
+			}
+		})
+	}
+}
+
+func TestImportCSVUpdates(t *testing.T) {
+	dir := t.TempDir()
+	deck := filepath.Join(dir, "deck.md")
+	writeTestFile(t, deck, testDeck)
+	csvPath := filepath.Join(dir, "deck.csv")
+	writeTestFile(t, csvPath, "Id,Box,Due,Front,Back\r\n"+
+		"tcp00002,0,2023-04-01,,\r\n"+
+		"bcast001,,,What is a broadcast?,A message to every node.\r\n")
+	summary, err := ImportCSV(csvPath, deck, ',')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Updated != 2 || summary.Cards != 0 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	want := strings.NewReplacer(
+		"A message to all nodes.", "A message to every node.",
+		"<!--mdfc:2;tcp00002;3;2023-03-03;v=r-->", "<!--mdfc:2;tcp00002;0;2023-04-01;v=r-->",
+	).Replace(testDeck)
+	if data, _ := os.ReadFile(deck); string(data) != want {
+		t.Errorf("deck is\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestImportCSVVariants(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "deck.csv")
+	// The rows of the sub-cards are in a different order than the variants of their cards.
+	writeTestFile(t, csvPath, "Front,Back,Id,Variant,Box\n"+
+		"TCP,Transmission Control Protocol,tcp00002,r,3\n"+
+		"TCP,Transmission Control Protocol,tcp00001,,1\n"+
+		"Cell,The {{c1::mitochondria}} makes {{c2::ATP}}.,cell0002,c2,5\n"+
+		"Cell,The {{c1::mitochondria}} makes {{c2::ATP}}.,cell0001,c1,4\n"+
+		"UDP,User Datagram Protocol,udp00002,r,2\n")
+	deck := filepath.Join(dir, "deck.md")
+	if _, err := ImportCSV(csvPath, deck, ','); err != nil {
+		t.Fatal(err)
+	}
+	file, err := readFile(deck, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"tcp00001": "/1", "tcp00002": "r/3", "cell0001": "c1/4", "cell0002": "c2/5",
+		"udp00002": "r/2"}
+	for _, c := range file.Cards {
+		w, ok := want[c.Id]
+		if !ok {
+			if c.Front != "UDP" || c.Variant != "" || c.Box != 0 {
+				t.Errorf("unexpected card %s %q of variant %q in box %d", c.Id, c.Front, c.Variant, c.Box)
+			}
+			continue
+		}
+		delete(want, c.Id)
+		if got := c.Variant + "/" + fmt.Sprint(c.Box); got != w {
+			t.Errorf("card %s: variant/box = %s, want %s", c.Id, got, w)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("missing cards %v", want)
+	}
+	if len(file.Cards) != 6 {
+		t.Errorf("got %d cards, want 6", len(file.Cards))
+	}
+}
+
+func TestImportCSVVariantMismatch(t *testing.T) {
+	dir := t.TempDir()
+	deck := filepath.Join(dir, "deck.md")
+	writeTestFile(t, deck, testDeck)
+	csvPath := filepath.Join(dir, "deck.csv")
+	// The ID of the reverse card with the variant of the forward card, e.g. of a row of another deck.
+	writeTestFile(t, csvPath, "Id,Variant,Box,Front,Back\n"+
+		"bcast001,,0,,\n"+
+		"tcp00002,,0,,\n")
+	_, err := ImportCSV(csvPath, deck, ',')
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 ||
+		!strings.Contains(err.Error(), `variant "" of card tcp00002`) {
+		t.Errorf("ImportCSV() = %v, want a parse error in line 3", err)
+	}
+	if data, _ := os.ReadFile(deck); string(data) != testDeck {
+		t.Errorf("deck changed to\n%s", data)
+	}
+
+	// Without a Variant column, the rows are matched by their ID only.
+	writeTestFile(t, csvPath, "Id,Box,Front\ntcp00002,0,\n")
+	if summary, err := ImportCSV(csvPath, deck, ','); err != nil || summary.Updated != 1 {
+		t.Errorf("ImportCSV() without variants = %+v, %v", summary, err)
+	}
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+var (
+	// ErrParse is matched by a ParseError, i.e. a file that can't be parsed.
+	ErrParse = errors.New("parse error")
+	// ErrIO is matched by an IOError, i.e. a file that can't be read or written.
+	ErrIO = errors.New("i/o error")
+	// ErrNoCards is returned if the given files contain no flashcards.
+	ErrNoCards = errors.New("no flashcards found")
+)
+
+// ParseError is an error in the content of a file, e.g. an invalid front matter.
+type ParseError struct {
+	Path string
+	// Line number, starting at 1. 0 if the error doesn't belong to a line, e.g. in a binary file.
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrParse
+}
+
+// IOError is an error while reading or writing a file.
+type IOError struct {
+	// Operation that failed, e.g. "read" or "write".
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *IOError) Error() string {
+	err := e.Err
+	// The path is already part of the message.
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Sprintf("could not %s %s: %v", e.Op, e.Path, err)
+}
+
+// Is Returns true if the target is ErrIO, so that an IOError matches both ErrIO and the underlying error.
+func (e *IOError) Is(target error) bool {
+	return target == ErrIO
+}
+
+func (e *IOError) Unwrap() error {
+	return e.Err
+}
@@ -1,9 +1,9 @@
 package internal
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -15,163 +15,596 @@ import (
 	gonanoid "github.com/matoous/go-nanoid"
 )
 
-// getMetadata extracts the metadata (ID, box, due date; embedded in html comment tag) from a line.
-func getMetadata(line string) (id, box, due string) {
-	re := regexp.MustCompile(`<!--\s*(.{4});(\d);(\d{4}-\d{2}-\d{2})\s*-->`)
-	matches := re.FindStringSubmatch(line)
-	if len(matches) == 4 {
-		return matches[1], matches[2], matches[3]
+// metadataVersion is the prefix of the current format of a card's metadata: `<!--mdfc:2;id;box;due[;key=value...]-->`.
+// The first format had no prefix, 4 character IDs, and single digit boxes. It is still read and upgraded whenever a
+// file is written.
+const metadataVersion = "mdfc:2;"
+
+// metadataPattern Returns the pattern of a card's metadata in any format with the given pattern of the ID.
+func metadataPattern(id string) string {
+	return `<!--\s*(?:` + metadataVersion + `)?(` + id + `);(\d+);(\d{4}-\d{2}-\d{2})((?:;[^;>]*)*?)\s*-->`
+}
+
+// Lengths of card IDs. IDs of the first metadata format have 4 characters; new IDs have defaultIdLength characters
+// unless the front matter specifies another length.
+const (
+	minIdLength     = 4
+	maxIdLength     = 32
+	defaultIdLength = 8
+)
+
+// idAlphabet are the characters of generated card IDs.
+const idAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// metadataRegex matches the metadata of a card (ID, box, due date, and optional scheduling state; embedded in html
+// comment tag).
+var metadataRegex = regexp.MustCompile(metadataPattern(`[^;>]{4,32}`))
+
+// reverseRegex matches the marker of a card that is also studied in reverse, i.e. from the back to the front side.
+var reverseRegex = regexp.MustCompile(`\s*\{reverse\}`)
+
+// markerRegex matches the markers of a card's heading that are not part of the question.
+var markerRegex = regexp.MustCompile(`\s*\{(?:reverse|order)\}`)
+
+// variantReverse is the variant of the reverse card of a card with the {reverse} marker.
+const variantReverse = "r"
+
+// getMetadata extracts the metadata (ID, box, due date, scheduling state; embedded in html comment tag) from a line.
+// The scheduling state is a list of `;key=value` pairs and may be empty.
+func getMetadata(line string) (id, box, due, state string) {
+	matches := metadataRegex.FindStringSubmatch(line)
+	if len(matches) == 5 {
+		return matches[1], matches[2], matches[3], matches[4]
 	}
 	return
 }
 
-// initializeMetadata initializes the metadata (ID, box, due date; embedded in html comment tag) for a new card.
-func initializeMetadata(line string) (updatedLine, id, box, due string) {
-	id = gonanoid.MustGenerate("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", 4)
-	box = "0"
-	due = time.Now().Format("2006-01-02")
-	// Make sure there are no unrecognized html comment tags present in the line
-	updatedLine = regexp.MustCompile(`\s*<!--.*-->`).ReplaceAllString(line, "")
-	updatedLine = fmt.Sprintf("%s <!--%s;%s;%s-->", updatedLine, id, box, due)
-	return
+// formatMetadata returns the html comment tag that holds the card's metadata. The scheduling state is only added if
+// the card's scheduler uses it.
+func formatMetadata(c *Card) string {
+	fields := []string{c.Id, strconv.Itoa(int(c.Box)), c.Due.Format("2006-01-02")}
+	if c.Variant != "" {
+		fields = append(fields, "v="+c.Variant)
+	}
+	if c.note != "" {
+		fields = append(fields, "n="+c.note)
+	}
+	if c.Ease != 0 {
+		fields = append(fields, "ease="+strconv.FormatFloat(c.Ease, 'f', 2, 64))
+	}
+	if c.Interval != 0 {
+		fields = append(fields, "ivl="+strconv.Itoa(int(c.Interval)))
+	}
+	if c.Stability != 0 {
+		fields = append(fields, "s="+strconv.FormatFloat(c.Stability, 'f', 2, 64))
+	}
+	if c.Difficulty != 0 {
+		fields = append(fields, "d="+strconv.FormatFloat(c.Difficulty, 'f', 2, 64))
+	}
+	if !c.LastReview.IsZero() {
+		fields = append(fields, "last="+c.LastReview.Format("2006-01-02"))
+	}
+	fields = append(fields, c.extraFields...)
+	return "<!--" + metadataVersion + strings.Join(fields, ";") + "-->"
+}
+
+// upgradeMetadata Returns the metadata comment in the current format. All fields are kept as they are.
+func upgradeMetadata(comment string) string {
+	if strings.Contains(comment, metadataVersion) {
+		return comment
+	}
+	return metadataRegex.ReplaceAllString(comment, "<!--"+metadataVersion+"$1;$2;$3$4-->")
+}
+
+// parseState parses the scheduling state of a card's metadata. Fields with unknown keys are kept as they are, so that
+// they are written back. Fields with an invalid value are skipped and returned as problems, so that the card can be
+// studied anyway.
+func parseState(c *Card, state string) (problems []string) {
+	for _, field := range strings.Split(state, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			if key != "" {
+				c.extraFields = append(c.extraFields, key)
+			}
+			continue
+		}
+		var err error
+		switch key {
+		case "v":
+			c.Variant = value
+		case "n":
+			c.note = value
+		case "ease":
+			c.Ease, err = strconv.ParseFloat(value, 64)
+		case "ivl":
+			var ivl uint64
+			ivl, err = strconv.ParseUint(value, 10, 32)
+			c.Interval = uint(ivl)
+		case "s":
+			c.Stability, err = strconv.ParseFloat(value, 64)
+		case "d":
+			c.Difficulty, err = strconv.ParseFloat(value, 64)
+		case "last":
+			c.LastReview, err = time.Parse("2006-01-02", value)
+		default:
+			c.extraFields = append(c.extraFields, strings.TrimSpace(field))
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid value %q of '%s' is ignored", value, key))
+		}
+	}
+	return problems
+}
+
+// newId generates a random card ID with the given number of characters.
+func newId(length uint) string {
+	return gonanoid.MustGenerate(idAlphabet, int(length))
+}
+
+// newMetadata returns the html comment tag with the metadata (ID, box, due date) of a new card of the given variant.
+func newMetadata(variant string, idLength uint) string {
+	id := newId(idLength)
+	metadata := fmt.Sprintf("<!--%s%s;0;%s", metadataVersion, id, time.Now().Format("2006-01-02"))
+	if variant != "" {
+		metadata += ";v=" + variant
+	}
+	return metadata + "-->"
+}
+
+// variantOf returns the variant of the card in the scheduling state of its metadata.
+func variantOf(state string) string {
+	var c Card
+	parseState(&c, state)
+	return c.Variant
+}
+
+// initializeHeading makes sure the heading line of a card has exactly one metadata comment per variant and that the
+// IDs are unique within the file. New IDs have idLength characters. Comments of variants that no longer exist, e.g.
+// of a removed cloze deletion, are dropped, except that a new highlight takes over the comment of a highlight that
+// no longer exists, e.g. after its text was corrected or of the first, numbered highlight variants. If another card
+// comes first, its comment takes over the note ID of the heading (see Card.noteId). Comments in the first format are
+// upgraded to the current one (see metadataVersion). Otherwise, the line is only changed if metadata is added or
+// removed.
+func initializeHeading(line string, variants []string, ids map[string]bool, idLength uint) string {
+	matches := metadataRegex.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		// Make sure there are no unrecognized html comment tags present in the line
+		line = regexp.MustCompile(`\s*<!--.*-->`).ReplaceAllString(line, "")
+	}
+	changed := false
+	existing := make(map[string]string)
+	// Comments of highlights that no longer exist in the order of the heading.
+	var orphans []string
+	for _, m := range matches {
+		variant := variantOf(m[4])
+		if _, ok := existing[variant]; ok || !slices.Contains(variants, variant) {
+			if isHighlightVariant(variant) {
+				orphans = append(orphans, m[0])
+			}
+			changed = true
+			continue
+		}
+		existing[variant] = m[0]
+	}
+
+	metadata := make([]string, 0, len(variants))
+	// The first comment holds the ID of the heading's note, which stays the same if the first card is removed.
+	var note string
+	if len(matches) > 0 {
+		var first Card
+		first.Id = matches[0][1]
+		parseState(&first, matches[0][4])
+		note = first.noteId()
+	}
+	for i, variant := range variants {
+		comment, ok := existing[variant]
+		kept := ok && comment == matches[0][0]
+		if !ok && isHighlightVariant(variant) && len(orphans) > 0 {
+			comment = variantFieldRegex.ReplaceAllString(orphans[0], ";v="+variant)
+			orphans = orphans[1:]
+		} else if !ok {
+			comment = newMetadata(variant, idLength)
+			changed = true
+		}
+		id, _, _, _ := getMetadata(comment)
+		for ids[id] {
+			comment, id = generateNewId(comment, idLength)
+			changed = true
+		}
+		ids[id] = true
+		if i > 0 {
+			comment = noteFieldRegex.ReplaceAllString(comment, "")
+		} else if !kept && note != "" && note != id {
+			// The first card was removed or another card comes first now.
+			comment = setNoteField(comment, note)
+		}
+		metadata = append(metadata, upgradeMetadata(comment))
+	}
+	if !changed {
+		return metadataRegex.ReplaceAllStringFunc(line, upgradeMetadata)
+	}
+	question := strings.TrimRight(metadataRegex.ReplaceAllString(line, ""), " \t")
+	return question + " " + strings.Join(metadata, " ")
+}
+
+// variantFieldRegex matches the variant field of a metadata comment.
+var variantFieldRegex = regexp.MustCompile(`;v=[0-9A-Za-z]*`)
+
+// noteFieldRegex matches the note field of a metadata comment, see Card.noteId.
+var noteFieldRegex = regexp.MustCompile(`;n=[^;>]*`)
+
+// setNoteField Returns the metadata comment with the given note ID. Like formatMetadata, the note field follows the
+// variant field.
+func setNoteField(comment, note string) string {
+	comment = noteFieldRegex.ReplaceAllString(comment, "")
+	at := metadataRegex.FindStringSubmatchIndex(comment)[8]
+	if loc := variantFieldRegex.FindStringIndex(comment); loc != nil {
+		at = loc[1]
+	}
+	return comment[:at] + ";n=" + note + comment[at:]
 }
 
 // generateNewId generates a new id for a card and updates the line with the new id.
-func generateNewId(line string) (updatedLine, id string) {
-	re := regexp.MustCompile(`<!--\s*(.{4});(\d);(\d{4}-\d{2}-\d{2})\s*-->`)
-	id = gonanoid.MustGenerate("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", 4)
-	updatedLine = re.ReplaceAllString(line, fmt.Sprintf("<!--%s;$2;$3-->", id))
+func generateNewId(line string, idLength uint) (updatedLine, id string) {
+	id = newId(idLength)
+	updatedLine = replaceId(metadataRegex, line, id)
 	return
 }
 
-// extractQuestion extracts the question from a second-level (or third, etc.) markdown header.
+// replaceId Replaces the ID of the metadata matched by re in the line with the given ID.
+func replaceId(re *regexp.Regexp, line, id string) string {
+	return re.ReplaceAllString(line, fmt.Sprintf("<!--%s%s;$2;$3$4-->", metadataVersion, id))
+}
+
+// extractQuestion extracts the question from the line of a heading, i.e. its text without the leading and closing
+// `#` characters, metadata, and other html comments. The {reverse} and {order} markers are not part of the question.
 func extractQuestion(line string) string {
-	re := regexp.MustCompile(`##\s+(.*)<!--`)
-	matches := re.FindStringSubmatch(line)
-	if len(matches) == 2 {
-		return strings.TrimSpace(matches[1])
-	}
-	return ""
-}
-
-// getCardFromLine extracts the card data from a second-level (or third, etc.) markdown header.
-func getCardFromLine(line, category string) (card Card) {
-	card.Category = category
-	id, box, due := getMetadata(line)
-	card.Id = id
-	boxUint, err := strconv.Atoi(box)
-	check(err)
-	card.Box = uint(boxUint)
-	card.Due, err = time.Parse("2006-01-02", due)
-	check(err)
-	card.Front = extractQuestion(line)
-	return
+	line = htmlCommentRegex.ReplaceAllString(line, "")
+	line = atxHeadingRegex.ReplaceAllString(line, "")
+	line = atxClosingRegex.ReplaceAllString(line, "")
+	return strings.TrimSpace(markerRegex.ReplaceAllString(line, ""))
 }
 
-// OpenFile Reads a markdown file containing flashcards and initializes the Session.
-func (s *Session) OpenFile(path string) error {
-	if path == "" {
-		return errors.New("no file specified")
+// cardVariants returns the variants of the cards of a heading: one per cloze deletion of the back side, the card and
+// its reverse if the heading of a basic card has the {reverse} marker, or the card only.
+func cardVariants(heading, back string) []string {
+	switch cardType(heading, back) {
+	case TypeCloze:
+		return clozeVariants(back)
+	case TypeBasic:
+		if reverseRegex.MatchString(metadataRegex.ReplaceAllString(heading, "")) {
+			return []string{"", variantReverse}
+		}
 	}
-	absPath, err := filepath.Abs(path)
-	check(err)
-	s.File = File{Path: absPath, BoxIntervals: boxIntervals}
+	return []string{""}
+}
 
-	if s.File.Path == "" {
-		return errors.New("no file specified")
+// getCardsFromLine extracts the card data from a second-level (or third, etc.) markdown header. A card with cloze
+// deletions or a reverse card has one metadata comment per sub-card. Invalid metadata is repaired in memory and
+// returned as problems: a card with an invalid due date is due today.
+func getCardsFromLine(line, category string, heading int) (cards []Card, problems []string) {
+	for _, m := range metadataRegex.FindAllStringSubmatch(line, -1) {
+		card := Card{Category: category, Id: m[1], heading: heading}
+		box, err := strconv.ParseUint(m[2], 10, 32)
+		if err != nil {
+			box = 0
+			problems = append(problems, fmt.Sprintf("invalid box %s of card %s, the card is moved to the first box", m[2], m[1]))
+		}
+		card.Box = uint(box)
+		card.Due, err = time.Parse("2006-01-02", m[3])
+		if err != nil {
+			y, mo, d := time.Now().Date()
+			card.Due = time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+			problems = append(problems, fmt.Sprintf("invalid due date %s of card %s, the card is due today", m[3], m[1]))
+		}
+		for _, p := range parseState(&card, m[4]) {
+			problems = append(problems, fmt.Sprintf("card %s: %s", m[1], p))
+		}
+		card.Front = extractQuestion(line)
+		cards = append(cards, card)
 	}
-	f, err := os.Open(s.File.Path)
-	if err != nil {
-		return errors.New("file not found")
+	return cards, problems
+}
+
+// ExpandPaths resolves the given paths to a list of absolute markdown file paths. A path can either be a file,
+// a directory (which is searched recursively for markdown files), or a glob pattern. Duplicates are removed while the
+// order of the given paths is kept.
+func ExpandPaths(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(path string) error {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return &IOError{Op: "resolve", Path: path, Err: err}
+		}
+		if !seen[absPath] {
+			seen[absPath] = true
+			files = append(files, absPath)
+		}
+		return nil
 	}
 
-	ids := make(map[string]bool)
-	lines := make([]string, 0)
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "#### ") {
-			id, _, _ := getMetadata(line)
-			if id == "" {
-				line, id, _, _ = initializeMetadata(line)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if strings.ContainsAny(path, "*?[") {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob pattern: %s", path)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", path)
 			}
-			for ids[id] {
-				line, id = generateNewId(line)
+			for _, m := range matches {
+				if info, err := os.Stat(m); err == nil && !info.IsDir() {
+					if err = add(m); err != nil {
+						return nil, err
+					}
+				}
 			}
-			ids[id] = true
+			continue
 		}
-		lines = append(lines, line)
-	}
-	err = f.Close()
-	check(err)
 
-	// Update the file with the new metadata
-	f, err = os.Create(s.File.Path)
-	_, err = f.WriteString(strings.Join(lines, "\n"))
-	check(err)
-	err = f.Sync()
-	check(err)
-	err = f.Close()
-	check(err)
-
-	// Go through all lines and initialize questions with ID if they don't have one.
-	// Also, initialize the File's Cards.
-	currentCard := Card{}
-	currentCategory := ""
-	readBack := false
-	appendCard := func() {
-		currentCard.Back = strings.TrimSpace(currentCard.Back)
-		s.File.Cards = append(s.File.Cards, currentCard)
-		currentCard = Card{}
-	}
-
-	for _, l := range lines {
-		switch {
-		case strings.HasPrefix(l, "# "):
-			if currentCard.Front != "" && currentCard.Back != "" {
-				appendCard()
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("file not found: %s", path)
+		}
+		if !info.IsDir() {
+			if err = add(path); err != nil {
+				return nil, err
 			}
-			currentCategory = strings.TrimSpace(l[2:])
-			readBack = false
-		case strings.HasPrefix(l, "## "), strings.HasPrefix(l, "### "), strings.HasPrefix(l, "#### "):
-			if currentCard.Front != "" && currentCard.Back != "" {
-				appendCard()
+			continue
+		}
+		var dirFiles []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
 			}
-			currentCard = getCardFromLine(l, currentCategory)
-			readBack = true
-		default:
-			if readBack {
-				currentCard.Back += l + "\n"
+			if d.IsDir() {
+				// Skip hidden directories such as .git
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isDeckFile(d.Name()) {
+				dirFiles = append(dirFiles, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, &IOError{Op: "read directory", Path: path, Err: err}
+		}
+		slices.Sort(dirFiles)
+		for _, f := range dirFiles {
+			if err = add(f); err != nil {
+				return nil, err
 			}
 		}
 	}
-	// End of file reached, append the last card
-	if readBack {
-		appendCard()
+
+	if len(files) == 0 {
+		return nil, errors.New("no file specified")
 	}
+	return files, nil
+}
 
-	if len(s.File.Cards) == 0 {
-		return errors.New("no flashcards found in file")
+// isDeckFile Checks whether a file found in a directory should be loaded as a deck. Copies created for sharing are
+// skipped, since they contain the same cards.
+func isDeckFile(name string) bool {
+	return strings.HasSuffix(name, ".md") && !strings.HasSuffix(name, ".share.md")
+}
+
+// OpenFile Reads one or more markdown files containing flashcards and initializes the Session. Each path can be a
+// file, a directory or a glob pattern (see ExpandPaths). The cards of all files are loaded into the same session.
+func (s *Session) OpenFile(paths ...string) error {
+	files, err := ExpandPaths(paths)
+	if err != nil {
+		return err
+	}
+
+	s.Files = make([]File, 0, len(files))
+	numberCards := 0
+	for _, path := range files {
+		if !s.ReadOnly {
+			lock, err := lockFile(path)
+			if err != nil {
+				s.Close()
+				return err
+			}
+			s.locks = append(s.locks, lock)
+		}
+		file, err := readFile(path, !s.ReadOnly)
+		if err != nil {
+			s.Close()
+			return err
+		}
+		numberCards += len(file.Cards)
+		s.Files = append(s.Files, file)
+	}
+	s.checkIds()
+
+	if numberCards == 0 {
+		if len(files) == 1 {
+			return fmt.Errorf("%w in file", ErrNoCards)
+		}
+		return fmt.Errorf("%w in files", ErrNoCards)
 	}
 
 	return nil
 }
 
+// readFile Reads a single markdown file, initializes missing metadata, and returns the File with its Cards. If write is
+// true, the file is written if metadata was added; all lines except the headings of cards are kept as they are.
+// Otherwise, the metadata of new cards only exists in memory.
+func readFile(path string, write bool) (File, error) {
+	file := File{Path: path, BoxIntervals: boxIntervals, Multipliers: defaultMultipliers, IdLength: defaultIdLength}
+	data, err := os.ReadFile(file.Path)
+	if err != nil {
+		return file, &IOError{Op: "read", Path: path, Err: err}
+	}
+	lines, endings := splitLines(string(data))
+
+	fm, frontMatterEnd, err := parseFrontMatter(path, lines)
+	if err != nil {
+		return file, err
+	}
+	file.FrontMatter = fm
+	fm.applyToFile(&file)
+	file.Scheduler = newScheduler(file.SchedulerName, &file)
+
+	sections, warnings := parseMarkdown(path, lines, frontMatterEnd)
+	file.Warnings = warnings
+	warn := func(line int, rule, format string, a ...any) {
+		file.Warnings = append(file.Warnings,
+			Warning{Path: path, Line: line + 1, Rule: rule, Message: fmt.Sprintf(format, a...)})
+	}
+
+	ids := make(map[string]bool)
+	// Heading lines as they are in the file, by their index, if metadata was added to them.
+	originals := make(map[int]string)
+	for _, sec := range sections {
+		if sec.level == 1 {
+			continue
+		}
+		line := lines[sec.line]
+		for _, m := range metadataRegex.FindAllStringSubmatch(line, -1) {
+			if ids[m[1]] {
+				warn(sec.line, "duplicate-id", "duplicate card ID %s, a new ID is generated", m[1])
+			}
+		}
+		for _, comment := range htmlCommentRegex.FindAllString(line, -1) {
+			if strings.Contains(comment, ";") && !metadataRegex.MatchString(comment) {
+				warn(sec.line, "malformed-metadata", "malformed metadata %s is ignored", comment)
+			}
+		}
+		back := strings.Join(lines[sec.start:sec.end], "\n")
+		lines[sec.line] = initializeHeading(line, cardVariants(line, back), ids, file.IdLength)
+		if lines[sec.line] != line {
+			originals[sec.line] = line
+		}
+	}
+
+	// Update the file with the new metadata
+	file.content = string(data)
+	if md := joinLines(lines, endings); write && md != file.content {
+		if err = writeFileAtomic(file.Path, []byte(md)); err != nil {
+			return file, &IOError{Op: "write", Path: path, Err: err}
+		}
+		file.content = md
+		originals = nil
+	}
+
+	currentCategory := ""
+	for _, sec := range sections {
+		line := lines[sec.line]
+		if sec.level == 1 {
+			currentCategory = extractQuestion(line)
+			continue
+		}
+		front := extractQuestion(line)
+		back := strings.TrimSpace(strings.Join(lines[sec.start:sec.end], "\n"))
+		if front == "" || back == "" {
+			warn(sec.line, "empty-card", "card without a front or back side is skipped")
+			continue
+		}
+		// Each sub-card of a card with cloze deletions is a card of its own.
+		t := cardType(line, back)
+		cards, problems := getCardsFromLine(line, currentCategory, sec.line)
+		for _, p := range problems {
+			warn(sec.line, "invalid-metadata", "%s", p)
+		}
+		_, leitner := file.Scheduler.(Leitner)
+		for _, c := range cards {
+			c.Back = back
+			c.Type = t
+			c.Path = file.Path
+			if original, ok := originals[sec.line]; ok {
+				c.unsaved = !slices.ContainsFunc(metadataRegex.FindAllStringSubmatch(original, -1),
+					func(m []string) bool { return m[1] == c.Id })
+			}
+			if lastBox := uint(len(file.BoxIntervals)) - 1; leitner && c.Box > lastBox {
+				// The box intervals in the front matter may have been shortened.
+				warn(sec.line, "invalid-metadata", "box %d of card %s is beyond the last box %d, it is moved to the "+
+					"last box", c.Box, c.Id, lastBox)
+				c.Box = lastBox
+			}
+			file.Cards = append(file.Cards, c)
+		}
+	}
+	slices.SortStableFunc(file.Warnings, func(a, b Warning) int { return a.Line - b.Line })
+
+	return file, nil
+}
+
 // updateCardInFile Updates the card's metadata in the file.
-func (s *Session) updateCardInFile(c *Card) {
-	data, err := os.ReadFile(s.File.Path)
-	check(err)
+func (s *Session) updateCardInFile(c *Card) error {
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return &IOError{Op: "read", Path: c.Path, Err: err}
+	}
 	md := string(data)
-	re := regexp.MustCompile(fmt.Sprintf(`<!--\s*%s;\d;\d{4}-\d{2}-\d{2}\s*-->`, c.Id))
-	md = re.ReplaceAllString(md, fmt.Sprintf("<!--%s;%d;%s-->", c.Id, c.Box, c.Due.Format("2006-01-02")))
-	err = os.WriteFile(s.File.Path, []byte(md), 0644)
-	check(err)
+	re := regexp.MustCompile(metadataPattern(regexp.QuoteMeta(c.Id)))
+	md = re.ReplaceAllLiteralString(md, formatMetadata(c))
+	if err = writeFileAtomic(c.Path, []byte(md)); err != nil {
+		return &IOError{Op: "write", Path: c.Path, Err: err}
+	}
+	// If the file was changed by another program in the meantime, it still needs to be reloaded.
+	if f := s.fileOf(c); f != nil && f.content == string(data) {
+		f.content = md
+	}
+	return nil
+}
+
+// writeFileAtomic Writes the data to a temporary file in the same directory and renames it to the given path, so that
+// the file is either completely written or not changed at all. The permissions of an existing file are kept.
+func writeFileAtomic(path string, data []byte) error {
+	// Write to the target of a symlink instead of replacing the symlink.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Persist the rename. Not all platforms can sync a directory, so errors are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
 }
 
-// CheckCategory Checks if the session's category is valid, meaning it is present in the File. If the input is empty, it
-// returns nil according to the CompareCategory function.
+// CheckCategory Checks if the session's category is valid, meaning it is present in one of the Files. If the input is
+// empty, it returns nil according to the CompareCategory function.
 func (s *Session) CheckCategory() error {
-	for _, c := range s.File.Cards {
+	for _, c := range s.cards() {
 		if CompareCategory(c.Category, s.Category) {
 			return nil
 		}
@@ -179,11 +612,11 @@ func (s *Session) CheckCategory() error {
 	return errors.New("category not found")
 }
 
-// ChooseCategory Lets the user choose a category from the file's headings.
+// ChooseCategory Lets the user choose a category from the headings of all files.
 func (s *Session) ChooseCategory() {
 	fmt.Println("Please select the category you want to study:")
 	var categories []string
-	for _, c := range s.File.Cards {
+	for _, c := range s.cards() {
 		if !slices.Contains(categories, c.Category) {
 			categories = append(categories, c.Category)
 		}
@@ -197,38 +630,51 @@ func (s *Session) ChooseCategory() {
 	s.Category = categories[choice-1]
 }
 
-// CreateCopyToShare Creates a copy of the file in the current directory, with the suffix '.share.md'. It reads
-// each line, resets the metadata, and writes the line to the new file.
+// CreateCopyToShare Creates a copy of the file in the current directory, with the suffix '.share.md'. It resets the
+// metadata of each card heading and copies all other lines as they are.
 func CreateCopyToShare(path string) error {
 	if path == "" {
 		return errors.New("no file specified")
 	}
 	absPath, err := filepath.Abs(path)
-	check(err)
-	f, err := os.Open(absPath)
 	if err != nil {
-		return errors.New("file not found")
+		return &IOError{Op: "resolve", Path: path, Err: err}
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return &IOError{Op: "read", Path: absPath, Err: err}
+	}
+	lines, endings := splitLines(string(data))
+	fm, frontMatterEnd, err := parseFrontMatter(absPath, lines)
+	if err != nil {
+		return err
+	}
+	idLength := uint(defaultIdLength)
+	if fm.IdLength != nil {
+		idLength = *fm.IdLength
+	}
+	sections, _ := parseMarkdown(absPath, lines, frontMatterEnd)
+
+	ids := make(map[string]bool)
+	for _, sec := range sections {
+		line := lines[sec.line]
+		if sec.level == 1 {
+			continue
+		}
+		// Reset the progress of each sub-card but keep its variant.
+		var variants []string
+		for _, m := range metadataRegex.FindAllStringSubmatch(line, -1) {
+			variants = append(variants, variantOf(m[4]))
+		}
+		if variants == nil {
+			variants = []string{""}
+		}
+		lines[sec.line] = initializeHeading(metadataRegex.ReplaceAllString(line, ""), variants, ids, idLength)
 	}
 
-	// Create the new file
 	newPath := strings.TrimSuffix(absPath, ".md") + ".share.md"
-	newF, err := os.Create(newPath)
-	check(err)
-
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, "### ") || strings.HasPrefix(line, "#### ") {
-			line, _, _, _ = initializeMetadata(line)
-		}
-		_, err := newF.WriteString(line + "\n")
-		check(err)
-	}
-	err = newF.Sync()
-	check(err)
-	err = f.Close()
-	check(err)
-	err = newF.Close()
-	check(err)
+	if err = writeFileAtomic(newPath, []byte(joinLines(lines, endings))); err != nil {
+		return &IOError{Op: "write", Path: newPath, Err: err}
+	}
 	return nil
 }
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetadataRoundTrip(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		card Card
+		want string
+	}{
+		{Card{Id: "abcd1234", Box: 0, Due: date("2023-03-01")}, "<!--mdfc:2;abcd1234;0;2023-03-01-->"},
+		{Card{Id: "x-Y_z9", Box: 12, Due: date("2024-12-31"), Variant: variantReverse},
+			"<!--mdfc:2;x-Y_z9;12;2024-12-31;v=r-->"},
+		{Card{Id: "abcd", Box: 3, Due: date("2023-03-01"), Variant: "c2", note: "efgh", Ease: 2.36, Interval: 15},
+			"<!--mdfc:2;abcd;3;2023-03-01;v=c2;n=efgh;ease=2.36;ivl=15-->"},
+		{Card{Id: "abcd1234", Box: 1, Due: date("2023-03-05"), Interval: 4, Stability: 3.71, Difficulty: 5.25,
+			LastReview: date("2023-03-01"), extraFields: []string{"future=1", "flag"}},
+			"<!--mdfc:2;abcd1234;1;2023-03-05;ivl=4;s=3.71;d=5.25;last=2023-03-01;future=1;flag-->"},
+	}
+	for _, tt := range tests {
+		got := formatMetadata(&tt.card)
+		if got != tt.want {
+			t.Errorf("formatMetadata() = %q, want %q", got, tt.want)
+		}
+		cards, problems := getCardsFromLine("## Question "+got, "Category", 7)
+		if len(problems) > 0 || len(cards) != 1 {
+			t.Fatalf("getCardsFromLine(%q) = %v, %v", got, cards, problems)
+		}
+		want := tt.card
+		want.Front, want.Category, want.heading = "Question", "Category", 7
+		if !reflect.DeepEqual(cards[0], want) {
+			t.Errorf("getCardsFromLine(%q) = %+v, want %+v", got, cards[0], want)
+		}
+	}
+}
+
+func TestGetCardsFromLine(t *testing.T) {
+	// Metadata of the first format is read as well, and invalid values are repaired.
+	line := "## Q {reverse} <!--abcd;2;2023-03-01--> <!--mdfc:2;efgh5678;99999999999;2023-03-01;v=r;ivl=x-->" +
+		" <!--mdfc:2;ijkl;1;2023-02-30-->"
+	cards, problems := getCardsFromLine(line, "C", 0)
+	if len(cards) != 3 || cards[0].Id != "abcd" || cards[0].Box != 2 || cards[1].Variant != variantReverse {
+		t.Fatalf("unexpected cards %+v", cards)
+	}
+	if cards[1].Box != 0 || cards[1].Interval != 0 {
+		t.Errorf("invalid box and interval are not reset: %+v", cards[1])
+	}
+	y, m, d := time.Now().Date()
+	if today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC); !cards[2].Due.Equal(today) {
+		t.Errorf("card with an invalid due date is due on %v, want today", cards[2].Due)
+	}
+	wantProblems := []string{"invalid box 99999999999 of card efgh5678", `card efgh5678: invalid value "x" of 'ivl'`,
+		"invalid due date 2023-02-30 of card ijkl"}
+	if len(problems) != len(wantProblems) {
+		t.Fatalf("problems = %q, want %q", problems, wantProblems)
+	}
+	for i, p := range problems {
+		if !strings.HasPrefix(p, wantProblems[i]) {
+			t.Errorf("problem %q, want %q", p, wantProblems[i])
+		}
+	}
+}
+
+func TestInitializeHeading(t *testing.T) {
+	tests := []struct {
+		name, line string
+		variants   []string
+		// Metadata comments of the result with the generated IDs and dates replaced by "new".
+		want string
+	}{
+		{"unchanged", "## Q <!--mdfc:2;abcd1234;1;2023-03-01-->", []string{""},
+			"## Q <!--mdfc:2;abcd1234;1;2023-03-01-->"},
+		{"new card", "## Q", []string{""}, "## Q <!--mdfc:2;new;0;new-->"},
+		{"upgraded", "## Q <!--abcd;1;2023-03-01-->", []string{""}, "## Q <!--mdfc:2;abcd;1;2023-03-01-->"},
+		{"new reverse card", "## Q {reverse} <!--mdfc:2;abcd1234;1;2023-03-01-->", []string{"", "r"},
+			"## Q {reverse} <!--mdfc:2;abcd1234;1;2023-03-01--> <!--mdfc:2;new;0;new;v=r-->"},
+		{"removed deletion",
+			"## Q <!--mdfc:2;abcd1234;1;2023-03-01;v=c1--> <!--mdfc:2;efgh5678;2;2023-03-01;v=c2-->",
+			[]string{"c2"}, "## Q <!--mdfc:2;efgh5678;2;2023-03-01;v=c2;n=abcd1234-->"},
+		// The first card keeps the note ID, which moves to the card that comes first.
+		{"kept note", "## Q <!--mdfc:2;efgh5678;2;2023-03-01;v=c2;n=abcd1234;ease=2.50-->", []string{"c2"},
+			"## Q <!--mdfc:2;efgh5678;2;2023-03-01;v=c2;n=abcd1234;ease=2.50-->"},
+		{"moved note", "## Q <!--mdfc:2;efgh5678;2;2023-03-01;v=c2;n=abcd1234;ease=2.50-->", []string{"c1", "c2"},
+			"## Q <!--mdfc:2;new;0;new;v=c1;n=abcd1234--> <!--mdfc:2;efgh5678;2;2023-03-01;v=c2;ease=2.50-->"},
+		{"removed card without a variant",
+			"## Q <!--mdfc:2;abcd1234;1;2023-03-01--> <!--mdfc:2;efgh5678;2;2023-03-01;v=r;ivl=3-->",
+			[]string{"r"}, "## Q <!--mdfc:2;efgh5678;2;2023-03-01;v=r;n=abcd1234;ivl=3-->"},
+		{"duplicate ID", "## Q <!--mdfc:2;taken123;1;2023-03-01-->", []string{""},
+			"## Q <!--mdfc:2;new;1;2023-03-01-->"},
+	}
+	newFields := func(line string) string {
+		for _, m := range metadataRegex.FindAllStringSubmatch(line, -1) {
+			id, due := m[1], m[3]
+			if !strings.HasPrefix(m[1], "abcd") && !strings.HasPrefix(m[1], "efgh") {
+				id = "new"
+			}
+			if due == time.Now().Format("2006-01-02") {
+				due = "new"
+			}
+			line = strings.Replace(line, m[0], "<!--"+metadataVersion+id+";"+m[2]+";"+due+m[4]+"-->", 1)
+		}
+		return line
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := map[string]bool{"taken123": true}
+			got := initializeHeading(tt.line, tt.variants, ids, defaultIdLength)
+			if newFields(got) != tt.want {
+				t.Errorf("initializeHeading(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+			for _, m := range metadataRegex.FindAllStringSubmatch(got, -1) {
+				if !ids[m[1]] {
+					t.Errorf("ID %s is not marked as used", m[1])
+				}
+				if m[1] != "abcd" && m[1] != "abcd1234" && m[1] != "efgh5678" && len(m[1]) != defaultIdLength {
+					t.Errorf("new ID %s has %d characters, want %d", m[1], len(m[1]), defaultIdLength)
+				}
+			}
+			if n := len(metadataRegex.FindAllString(got, -1)); n != len(tt.variants) {
+				t.Errorf("got %d metadata comments, want %d", n, len(tt.variants))
+			}
+		})
+	}
+}
+
+func TestReadFileBoxBeyondLastBox(t *testing.T) {
+	md := "---\nbox_intervals: [0, 1, 3]\n---\n# C\n\n## Q <!--mdfc:2;abcd1234;5;2023-03-01-->\n\nA\n"
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, md)
+	file, err := readFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Cards) != 1 || file.Cards[0].Box != 2 {
+		t.Fatalf("card is not moved to the last box: %+v", file.Cards)
+	}
+	if len(file.Warnings) != 1 || file.Warnings[0].Rule != "invalid-metadata" || file.Warnings[0].Line != 6 {
+		t.Errorf("warnings = %v, want invalid-metadata in line 6", file.Warnings)
+	}
+}
@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FrontMatter holds the settings that can be specified in a YAML front matter block at the top of a markdown file.
+// Fields that are not specified in the front matter are nil.
+type FrontMatter struct {
+	BoxIntervals  []uint
+	NumberCards   *uint
+	FutureDaysDue *uint
+	WrapLines     *uint
+	Sequential    *bool
+	ShowCategory  *bool
+	GitCommit     *bool
+	TypeAnswer    *bool
+	// Name of the scheduler, see newScheduler.
+	Scheduler *string
+	// Desired probability of recalling a card when it is due. Only used by the FSRS scheduler.
+	Retention *float64
+	Hard      *float32
+	Okay      *float32
+	Easy      *float32
+	// Number of characters of the IDs of new cards.
+	IdLength *uint
+}
+
+// Multipliers are the factors by which a box interval is multiplied depending on how difficult it was to remember a
+// card.
+type Multipliers struct {
+	Hard, Okay, Easy float32
+}
+
+var defaultMultipliers = Multipliers{Hard: Hard, Okay: Okay, Easy: Easy}
+
+// of Returns the multiplier for the given difficulty.
+func (m Multipliers) of(difficulty float32) float32 {
+	switch difficulty {
+	case Hard:
+		return m.Hard
+	case Okay:
+		return m.Okay
+	case Easy:
+		return m.Easy
+	}
+	return 0
+}
+
+// Names of the session settings that can be specified in the front matter as well as with a command-line flag.
+const (
+	SettingNumberCards   = "numberCards"
+	SettingFutureDaysDue = "futureDaysDue"
+	SettingWrapLines     = "wrapLines"
+	SettingSequential    = "sequential"
+	SettingShowCategory  = "showCategory"
+	SettingGitCommit     = "gitCommit"
+	SettingTypeAnswer    = "typeAnswer"
+)
+
+// frontMatterError formats an error for an invalid front matter. The line number is 1-based.
+func frontMatterError(path string, line int, format string, a ...any) error {
+	return &ParseError{Path: path, Line: line, Message: "invalid front matter: " + fmt.Sprintf(format, a...)}
+}
+
+// normalizeKey makes front matter keys case-insensitive and allows snake_case and kebab-case spellings.
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.ReplaceAll(key, "_", "")
+	return strings.ReplaceAll(key, "-", "")
+}
+
+// stripComment removes a trailing YAML comment from a value.
+func stripComment(value string) string {
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	if strings.HasPrefix(value, "#") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// unquote removes surrounding single or double quotes from a scalar value.
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseFrontMatter parses the YAML front matter at the beginning of the given lines. Only a flat subset of YAML is
+// supported: scalar values, and lists either in flow style (`[0, 1, 2]`) or in block style (`- 0`). Unknown keys are
+// ignored so that the front matter can be shared with other tools. It returns the index of the first line after the
+// front matter, which is 0 if the file has no front matter.
+func parseFrontMatter(path string, lines []string) (fm FrontMatter, end int, err error) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return fm, 0, nil
+	}
+
+	end = -1
+	for i := 1; i < len(lines); i++ {
+		if l := strings.TrimRight(lines[i], " \t"); l == "---" || l == "..." {
+			end = i + 1
+			break
+		}
+	}
+	if end == -1 {
+		return fm, 0, frontMatterError(path, 1, "missing closing '---'")
+	}
+
+	for i := 1; i < end-1; i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(trimmed, "- ") {
+			// Indented lines or list items belong to a key that is not supported.
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return fm, 0, frontMatterError(path, i+1, "expected 'key: value', got %q", trimmed)
+		}
+		key = strings.TrimSpace(key)
+		value = stripComment(value)
+		lineNr := i + 1
+
+		// Collect block style list items.
+		var items []string
+		var itemLines []int
+		for i+1 < end-1 {
+			next := strings.TrimSpace(lines[i+1])
+			if next == "" || strings.HasPrefix(next, "#") {
+				i++
+				continue
+			}
+			if next != "-" && !strings.HasPrefix(next, "- ") {
+				break
+			}
+			i++
+			items = append(items, stripComment(strings.TrimPrefix(next, "-")))
+			itemLines = append(itemLines, i+1)
+		}
+
+		switch normalizeKey(key) {
+		case "boxintervals":
+			if len(items) == 0 {
+				if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+					return fm, 0, frontMatterError(path, lineNr, "%s must be a list of days, e.g. [0, 1, 2, 4]", key)
+				}
+				for _, item := range strings.Split(value[1:len(value)-1], ",") {
+					items = append(items, strings.TrimSpace(item))
+				}
+			} else if value != "" {
+				return fm, 0, frontMatterError(path, lineNr, "%s must either be a flow or a block list", key)
+			}
+			fm.BoxIntervals = make([]uint, 0, len(items))
+			for j, item := range items {
+				if len(itemLines) > 0 {
+					lineNr = itemLines[j]
+				}
+				n, err := strconv.ParseUint(unquote(item), 10, 32)
+				if err != nil {
+					return fm, 0, frontMatterError(path, lineNr, "%s: %q is not a number of days", key, item)
+				}
+				fm.BoxIntervals = append(fm.BoxIntervals, uint(n))
+			}
+			if len(fm.BoxIntervals) == 0 {
+				return fm, 0, frontMatterError(path, lineNr, "%s must contain at least one box", key)
+			}
+		case "numbercards":
+			fm.NumberCards, err = parseUintValue(path, lineNr, key, value)
+		case "futuredaysdue":
+			fm.FutureDaysDue, err = parseUintValue(path, lineNr, key, value)
+		case "wraplines":
+			fm.WrapLines, err = parseUintValue(path, lineNr, key, value)
+		case "sequential":
+			fm.Sequential, err = parseBoolValue(path, lineNr, key, value)
+		case "showcategory":
+			fm.ShowCategory, err = parseBoolValue(path, lineNr, key, value)
+		case "gitcommit":
+			fm.GitCommit, err = parseBoolValue(path, lineNr, key, value)
+		case "typeanswer":
+			fm.TypeAnswer, err = parseBoolValue(path, lineNr, key, value)
+		case "scheduler":
+			name := strings.ToLower(unquote(value))
+			switch name {
+			case SchedulerLeitner, SchedulerSM2, SchedulerFSRS:
+				fm.Scheduler = &name
+			default:
+				return fm, 0, frontMatterError(path, lineNr, "%s must be one of %s, %s or %s, got %q", key,
+					SchedulerLeitner, SchedulerSM2, SchedulerFSRS, value)
+			}
+		case "retention":
+			r, err := strconv.ParseFloat(unquote(value), 64)
+			if err != nil || math.IsNaN(r) || math.IsInf(r, 0) || r <= 0 || r >= 1 {
+				return fm, 0, frontMatterError(path, lineNr, "%s must be a probability between 0 and 1, got %q",
+					key, value)
+			}
+			fm.Retention = &r
+		case "hard":
+			fm.Hard, err = parseMultiplierValue(path, lineNr, key, value)
+		case "okay":
+			fm.Okay, err = parseMultiplierValue(path, lineNr, key, value)
+		case "easy":
+			fm.Easy, err = parseMultiplierValue(path, lineNr, key, value)
+		case "idlength":
+			n, err := strconv.ParseUint(unquote(value), 10, 32)
+			if err != nil || n < minIdLength || n > maxIdLength {
+				return fm, 0, frontMatterError(path, lineNr, "%s must be a number between %d and %d, got %q", key,
+					minIdLength, maxIdLength, value)
+			}
+			u := uint(n)
+			fm.IdLength = &u
+		}
+		if err != nil {
+			return fm, 0, err
+		}
+	}
+
+	return fm, end, nil
+}
+
+func parseUintValue(path string, line int, key, value string) (*uint, error) {
+	n, err := strconv.ParseUint(unquote(value), 10, 32)
+	if err != nil {
+		return nil, frontMatterError(path, line, "%s must be a non-negative number, got %q", key, value)
+	}
+	u := uint(n)
+	return &u, nil
+}
+
+func parseBoolValue(path string, line int, key, value string) (*bool, error) {
+	var b bool
+	switch strings.ToLower(unquote(value)) {
+	case "true", "yes", "on":
+		b = true
+	case "false", "no", "off":
+		b = false
+	default:
+		return nil, frontMatterError(path, line, "%s must be true or false, got %q", key, value)
+	}
+	return &b, nil
+}
+
+func parseMultiplierValue(path string, line int, key, value string) (*float32, error) {
+	f, err := strconv.ParseFloat(unquote(value), 32)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || f <= 0 {
+		return nil, frontMatterError(path, line, "%s must be a positive number, got %q", key, value)
+	}
+	f32 := float32(f)
+	return &f32, nil
+}
+
+// applyToFile Applies the deck specific settings (scheduler, box intervals, difficulty multipliers and ID length) to
+// the file.
+func (fm FrontMatter) applyToFile(f *File) {
+	if fm.Scheduler != nil {
+		f.SchedulerName = *fm.Scheduler
+	}
+	if fm.Retention != nil {
+		f.RequestRetention = *fm.Retention
+	}
+	if fm.BoxIntervals != nil {
+		f.BoxIntervals = fm.BoxIntervals
+	}
+	if fm.Hard != nil {
+		f.Multipliers.Hard = *fm.Hard
+	}
+	if fm.Okay != nil {
+		f.Multipliers.Okay = *fm.Okay
+	}
+	if fm.Easy != nil {
+		f.Multipliers.Easy = *fm.Easy
+	}
+	if fm.IdLength != nil {
+		f.IdLength = *fm.IdLength
+	}
+}
+
+// ApplyFrontMatter Applies the session settings of the files' front matter to the session. Settings contained in
+// flagsSet were specified as command-line flags and are not overridden. If several files specify the same setting,
+// the first file wins.
+func (s *Session) ApplyFrontMatter(flagsSet map[string]bool) {
+	for i := len(s.Files) - 1; i >= 0; i-- {
+		fm := s.Files[i].FrontMatter
+		if fm.NumberCards != nil && !flagsSet[SettingNumberCards] {
+			s.NumberCards = *fm.NumberCards
+		}
+		if fm.FutureDaysDue != nil && !flagsSet[SettingFutureDaysDue] {
+			s.FutureDaysDue = *fm.FutureDaysDue
+		}
+		if fm.WrapLines != nil && !flagsSet[SettingWrapLines] {
+			s.WrapLines = *fm.WrapLines
+		}
+		if fm.Sequential != nil && !flagsSet[SettingSequential] {
+			s.Sequential = *fm.Sequential
+		}
+		if fm.ShowCategory != nil && !flagsSet[SettingShowCategory] {
+			s.ShowCategory = *fm.ShowCategory
+		}
+		if fm.GitCommit != nil && !flagsSet[SettingGitCommit] {
+			s.GitCommit = *fm.GitCommit
+		}
+		if fm.TypeAnswer != nil && !flagsSet[SettingTypeAnswer] {
+			s.TypeAnswer = *fm.TypeAnswer
+		}
+	}
+}
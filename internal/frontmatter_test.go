@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFrontMatterNumbers(t *testing.T) {
+	tests := []struct {
+		line    string
+		wantErr string
+	}{
+		{"hard: 0.5", ""},
+		{"easy: '2'", ""},
+		{"retention: 0.85", ""},
+		{"hard: 0", "hard must be a positive number"},
+		{"okay: -1", "okay must be a positive number"},
+		{"easy: x", "easy must be a positive number"},
+		{"hard: NaN", "hard must be a positive number"},
+		{"okay: nan", "okay must be a positive number"},
+		{"easy: +Inf", "easy must be a positive number"},
+		{"hard: infinity", "hard must be a positive number"},
+		{"easy: 1e39", "easy must be a positive number"},
+		{"retention: NaN", "retention must be a probability between 0 and 1"},
+		{"retention: -inf", "retention must be a probability between 0 and 1"},
+		{"retention: 1", "retention must be a probability between 0 and 1"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseFrontMatter("deck.md", []string{"---", tt.line, "---"})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("parseFrontMatter(%q) error = %v", tt.line, err)
+			}
+			continue
+		}
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) || parseErr.Line != 2 || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseFrontMatter(%q) error = %v, want a parse error in line 2 containing %q", tt.line, err,
+				tt.wantErr)
+		}
+	}
+}
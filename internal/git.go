@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotInRepository is returned if a flashcard file is not inside a git repository.
+var ErrNotInRepository = errors.New("not inside a git repository")
+
+// runGit Runs a git command in the given directory and returns its trimmed standard output. Git runs with the C
+// locale, so that its messages can be recognized regardless of the user's language.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", errors.New("git is not installed")
+	}
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitRepositoryRoot Returns the top-level directory of the git repository that contains the given file.
+func gitRepositoryRoot(path string) (string, error) {
+	root, err := runGit(filepath.Dir(path), "rev-parse", "--show-toplevel")
+	if err != nil {
+		if strings.Contains(err.Error(), "not a git repository") {
+			return "", ErrNotInRepository
+		}
+		return "", err
+	}
+	// Resolve symlinks so that the paths can be compared to the root.
+	return filepath.EvalSymlinks(root)
+}
+
+// commitMessage Returns a commit message that summarises the session.
+func (s *Session) commitMessage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "mdfc: study session on %s (%d reviews)\n\n", time.Now().Format("2006-01-02"), s.results.total())
+	fmt.Fprintf(&b, "Not remembered: %d\n", s.results.NotRemembered)
+	fmt.Fprintf(&b, "Hard: %d\n", s.results.Hard)
+	fmt.Fprintf(&b, "Okay: %d\n", s.results.Okay)
+	fmt.Fprintf(&b, "Easy: %d\n", s.results.Easy)
+
+	var cards []Card
+	for _, f := range s.Files {
+		cards = append(cards, f.Cards...)
+	}
+	if nextSession, err := FindClosestDate(cards); err == nil {
+		fmt.Fprintf(&b, "Next due date: %s\n", nextSession.Format("2006-01-02"))
+	} else {
+		b.WriteString("Next due date: cards are still due today\n")
+	}
+	return b.String()
+}
+
+// repositoryPath Returns the path of the file relative to the root of its repository with forward slashes.
+func repositoryPath(root, path string) (string, error) {
+	// Resolve symlinks, like the ones of the root.
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// CommitToGit Commits the session's files and their review histories to the git repositories they are in. Files that
+// are not inside a repository are skipped, and so are ignored review histories. Only these files are committed, and
+// the commit is refused if other changes are already staged in a repository, so that unrelated work does not end up
+// in the commit. If the commit fails in a repository, the other repositories are committed anyway and the error tells
+// which repositories were committed.
+func (s *Session) CommitToGit() error {
+	// Group the files by the repository they are in.
+	repositories := make(map[string][]string)
+	var roots []string
+	for _, f := range s.Files {
+		root, err := gitRepositoryRoot(f.Path)
+		if errors.Is(err, ErrNotInRepository) {
+			fmt.Printf("Git: %s is %v, skipping commit.\n", filepath.Base(f.Path), err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := repositories[root]; !ok {
+			roots = append(roots, root)
+		}
+		// The review history of the session belongs to the commit, unless it is ignored on purpose.
+		paths := []string{f.Path}
+		if _, err = os.Stat(HistoryPath(f.Path)); err == nil {
+			paths = append(paths, HistoryPath(f.Path))
+		}
+		for i, path := range paths {
+			rel, err := repositoryPath(root, path)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				status, err := runGit(root, "status", "--porcelain", "--ignored", "--", rel)
+				if err != nil {
+					return err
+				}
+				if strings.HasPrefix(status, "!!") {
+					continue
+				}
+			}
+			repositories[root] = append(repositories[root], rel)
+		}
+	}
+
+	message := s.commitMessage()
+	var committed, failed []string
+	for _, root := range roots {
+		files := repositories[root]
+		ok, err := commitFiles(root, files, message)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", root, err))
+			continue
+		}
+		if ok {
+			committed = append(committed, root)
+			fmt.Printf("Git: committed %d file(s) in %s\n", len(files), root)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if len(roots) == 1 {
+		return errors.New(failed[0])
+	}
+	msg := fmt.Sprintf("commit failed in %d of %d repositories", len(failed), len(roots))
+	if len(committed) > 0 {
+		msg += fmt.Sprintf(" (committed in %s)", strings.Join(committed, ", "))
+	}
+	return errors.New(msg + ":\n" + strings.Join(failed, "\n"))
+}
+
+// commitFiles Commits the files, given relative to the root of the repository, with the message. It returns false if
+// the files have no changes to commit.
+func commitFiles(root string, files []string, message string) (bool, error) {
+	staged, err := runGit(root, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return false, err
+	}
+	for _, name := range strings.Split(staged, "\x00") {
+		if name != "" && !slices.Contains(files, name) {
+			return false, fmt.Errorf("refusing to commit: unrelated changes are staged (%s)", name)
+		}
+	}
+
+	changed, err := runGit(root, append([]string{"status", "--porcelain", "--"}, files...)...)
+	if err != nil || changed == "" {
+		return false, err
+	}
+	if _, err = runGit(root, append([]string{"add", "--"}, files...)...); err != nil {
+		return false, err
+	}
+	// The pathspec limits the commit to the files, even if something else was staged in the meantime.
+	if _, err = runGit(root, append([]string{"commit", "-q", "-m", message, "--"}, files...)...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
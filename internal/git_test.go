@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRepository Returns the path of a new git repository with a committed deck file, or skips the test if git is
+// not installed.
+func newTestRepository(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	writeTestFile(t, filepath.Join(dir, "deck.md"), "# C\n\n## Q <!--mdfc:2;abcd1234;0;2023-03-01-->\n\nA\n")
+	for _, args := range [][]string{{"init", "-q"}, {"add", "deck.md"}, {"commit", "-q", "-m", "init"}} {
+		if _, err := runGit(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitToGit(t *testing.T) {
+	tests := []struct {
+		name string
+		// Prepares the repository after the session changed the deck and wrote its review history.
+		prepare func(t *testing.T, dir string)
+		wantErr string
+		// Trimmed output of `git status --porcelain` after the commit.
+		wantStatus string
+	}{
+		{name: "deck and history", prepare: func(*testing.T, string) {}},
+		{name: "ignored history", prepare: func(t *testing.T, dir string) {
+			writeTestFile(t, filepath.Join(dir, ".git", "info", "exclude"), "*.log.jsonl\n")
+		}},
+		{name: "unrelated staged changes", prepare: func(t *testing.T, dir string) {
+			writeTestFile(t, filepath.Join(dir, "other.txt"), "x")
+			if _, err := runGit(dir, "add", "other.txt"); err != nil {
+				t.Fatal(err)
+			}
+		}, wantErr: "unrelated changes are staged (other.txt)",
+			wantStatus: "M deck.md\nA  other.txt\n?? deck.log.jsonl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepository(t)
+			path := filepath.Join(dir, "deck.md")
+			writeTestFile(t, path, "# C\n\n## Q <!--mdfc:2;abcd1234;1;2023-03-02-->\n\nA\n")
+			writeTestFile(t, HistoryPath(path), `{"id":"abcd1234"}`+"\n")
+			tt.prepare(t, dir)
+
+			s := &Session{Files: []File{{Path: path}}, results: TestModeResults{Okay: 1}}
+			err := s.CommitToGit()
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("CommitToGit() = %v, want error %q", err, tt.wantErr)
+			}
+			status, err := runGit(dir, "status", "--porcelain")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("git status = %q, want %q", status, tt.wantStatus)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			message, err := runGit(dir, "log", "-1", "--format=%B")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(message, "mdfc: study session on ") || !strings.Contains(message, "Okay: 1") {
+				t.Errorf("unexpected commit message %q", message)
+			}
+		})
+	}
+}
+
+func TestCommitToGitOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	// The repository is detected independently of the user's language.
+	t.Setenv("LANG", "de_DE.UTF-8")
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	path := filepath.Join(dir, "deck.md")
+	writeTestFile(t, path, "# C\n")
+	if _, err := gitRepositoryRoot(path); err != ErrNotInRepository {
+		t.Fatalf("gitRepositoryRoot() = %v, want ErrNotInRepository", err)
+	}
+	s := &Session{Files: []File{{Path: path}}}
+	if err := s.CommitToGit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommitToGitPartialFailure(t *testing.T) {
+	var paths []string
+	var dirs []string
+	for i := 0; i < 2; i++ {
+		dir := newTestRepository(t)
+		path := filepath.Join(dir, "deck.md")
+		writeTestFile(t, path, "# C\n\n## Q <!--mdfc:2;abcd1234;1;2023-03-02-->\n\nA\n")
+		writeTestFile(t, HistoryPath(path), `{"id":"abcd1234"}`+"\n")
+		paths = append(paths, path)
+		dirs = append(dirs, dir)
+	}
+	// The first repository has an unrelated change that isn't staged, the second one an unrelated staged change.
+	writeTestFile(t, filepath.Join(dirs[0], "other.txt"), "x")
+	writeTestFile(t, filepath.Join(dirs[1], "other.txt"), "x")
+	if _, err := runGit(dirs[1], "add", "other.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Session{Files: []File{{Path: paths[0]}, {Path: paths[1]}}}
+	err := s.CommitToGit()
+	if err == nil || !strings.Contains(err.Error(), "commit failed in 1 of 2 repositories") ||
+		!strings.Contains(err.Error(), "unrelated changes are staged (other.txt)") {
+		t.Fatalf("CommitToGit() = %v, want a partial failure", err)
+	}
+	root, _ := filepath.EvalSymlinks(dirs[0])
+	if !strings.Contains(err.Error(), "committed in "+root) {
+		t.Errorf("error %q doesn't tell which repository was committed", err)
+	}
+
+	// Only the deck and its history are committed in the first repository.
+	files, err := runGit(dirs[0], "show", "--name-only", "--format=", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != "deck.log.jsonl\ndeck.md" {
+		t.Errorf("committed files = %q", files)
+	}
+	if status, _ := runGit(dirs[0], "status", "--porcelain"); status != "?? other.txt" {
+		t.Errorf("git status of the first repository = %q", status)
+	}
+	if status, _ := runGit(dirs[1], "status", "--porcelain"); status != "M deck.md\nA  other.txt\n?? deck.log.jsonl" {
+		t.Errorf("git status of the second repository = %q", status)
+	}
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Review is an entry of a deck's review history. The history is stored next to the deck in a JSON Lines file with the
+// suffix '.log.jsonl' and only ever appended to.
+type Review struct {
+	Id         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Grade      string    `json:"grade"`
+	PrevBox    uint      `json:"prevBox"`
+	Box        uint      `json:"box"`
+	Due        string    `json:"due"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// Grades as they are stored in the review history.
+const (
+	GradeNotRemembered = "not-remembered"
+	GradeHard          = "hard"
+	GradeOkay          = "okay"
+	GradeEasy          = "easy"
+)
+
+// gradeName Returns the name of the difficulty as it is stored in the review history.
+func gradeName(difficulty float32) string {
+	switch difficulty {
+	case Hard:
+		return GradeHard
+	case Okay:
+		return GradeOkay
+	case Easy:
+		return GradeEasy
+	}
+	return GradeNotRemembered
+}
+
+// HistoryPath Returns the path of the review history of the given deck file.
+func HistoryPath(path string) string {
+	return strings.TrimSuffix(path, ".md") + ".log.jsonl"
+}
+
+// appendReview Appends a review to the review history of the given deck file. An incomplete last line, which an
+// interrupted write leaves behind, is replaced.
+func appendReview(path string, r Review) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(HistoryPath(path), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err = seekEndOfLastLine(f); err == nil {
+		_, err = f.Write(append(line, '\n'))
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// seekEndOfLastLine Moves to the end of the last complete line of the history and removes everything after it.
+func seekEndOfLastLine(f *os.File) error {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil || size == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err = f.ReadAt(last, size-1); err != nil || last[0] == '\n' {
+		return err
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, size))
+	if err != nil {
+		return err
+	}
+	end := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if err = f.Truncate(end); err != nil {
+		return err
+	}
+	_, err = f.Seek(end, io.SeekStart)
+	return err
+}
+
+// ReadHistory Reads the review history of the given deck file. If the deck has no history yet, it returns an empty
+// history. An incomplete last line without a newline, which an interrupted write leaves behind, is ignored.
+func ReadHistory(path string) ([]Review, error) {
+	data, err := os.ReadFile(HistoryPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reviews []Review
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var r Review
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("%s:%d: invalid review: %v", HistoryPath(path), i+1, err)
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, nil
+}
+
+// historySize Returns the size of the review history of the given deck file in bytes.
+func historySize(path string) int64 {
+	info, err := os.Stat(HistoryPath(path))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// truncateHistory Removes the reviews that were appended to the review history of the given deck file after it had
+// the given size. If the history was empty before, the file is removed.
+func truncateHistory(path string, size int64) error {
+	if size == 0 {
+		err := os.Remove(HistoryPath(path))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return os.Truncate(HistoryPath(path), size)
+}
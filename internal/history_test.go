@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	deck := filepath.Join(t.TempDir(), "deck.md")
+	if history, err := ReadHistory(deck); err != nil || history != nil {
+		t.Fatalf("ReadHistory() of a deck without a history = %v, %v", history, err)
+	}
+	reviews := []Review{
+		{Id: "abcd1234", Time: time.Date(2023, 3, 1, 9, 30, 0, 0, time.UTC), Grade: GradeOkay, PrevBox: 0, Box: 1,
+			Due: "2023-03-02", DurationMs: 4200},
+		{Id: "efgh5678", Time: time.Date(2023, 3, 1, 9, 31, 0, 0, time.FixedZone("", 3600)),
+			Grade: GradeNotRemembered, PrevBox: 3, Box: 0, Due: "2023-03-01"},
+	}
+	for _, r := range reviews {
+		if err := appendReview(deck, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, err := ReadHistory(deck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != len(reviews) {
+		t.Fatalf("got %d reviews, want %d", len(history), len(reviews))
+	}
+	for i := range reviews {
+		if !history[i].Time.Equal(reviews[i].Time) {
+			t.Errorf("review %d at %v, want %v", i, history[i].Time, reviews[i].Time)
+		}
+		history[i].Time = reviews[i].Time
+		if !reflect.DeepEqual(history[i], reviews[i]) {
+			t.Errorf("review %d = %+v, want %+v", i, history[i], reviews[i])
+		}
+	}
+
+	// An interrupted write leaves an incomplete last line, which is ignored and replaced by the next review.
+	f, err := os.OpenFile(HistoryPath(deck), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString(`{"id":"ijkl","time":"2023-03-0`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if history, err = ReadHistory(deck); err != nil || len(history) != 2 {
+		t.Fatalf("ReadHistory() with an incomplete last line = %d reviews, %v", len(history), err)
+	}
+	if err = appendReview(deck, Review{Id: "mnop", Grade: GradeEasy}); err != nil {
+		t.Fatal(err)
+	}
+	if history, err = ReadHistory(deck); err != nil || len(history) != 3 || history[2].Id != "mnop" {
+		t.Fatalf("ReadHistory() after replacing the incomplete line = %v, %v", history, err)
+	}
+	data, _ := os.ReadFile(HistoryPath(deck))
+	if lines := strings.Split(string(data), "\n"); len(lines) != 4 || lines[3] != "" {
+		t.Errorf("unexpected history %q", data)
+	}
+
+	// An invalid line before the last one is an error.
+	writeTestFile(t, HistoryPath(deck), "{\"id\":\n"+string(data))
+	if _, err = ReadHistory(deck); err == nil || !strings.Contains(err.Error(), "deck.log.jsonl:1: invalid review") {
+		t.Errorf("ReadHistory() with an invalid line = %v, want an error in line 1", err)
+	}
+}
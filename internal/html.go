@@ -0,0 +1,352 @@
+package internal
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// htmlTagRegex matches an html tag with its name and attributes, or an html comment.
+	htmlTagRegex = regexp.MustCompile(`<!--[\s\S]*?-->|<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	// htmlAttributeRegex matches an attribute of an html tag with its value.
+	htmlAttributeRegex = regexp.MustCompile(`([a-zA-Z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	// whitespaceRegex matches a sequence of whitespace, which is shown as a single space in html.
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+	// blankLinesRegex matches more than one blank line.
+	blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+	// blockStartRegex matches a line that would start a heading or end a paragraph as a setext heading if it was
+	// written to a deck file.
+	blockStartRegex = regexp.MustCompile(`^( {0,3})(#|=+[ \t]*$|-+[ \t]*$)`)
+)
+
+// htmlAttribute Returns the value of the attribute of an html tag, or an empty string.
+func htmlAttribute(attributes, name string) string {
+	for _, m := range htmlAttributeRegex.FindAllStringSubmatch(attributes, -1) {
+		if strings.EqualFold(m[1], name) {
+			return html.UnescapeString(strings.Trim(m[2], `"'`))
+		}
+	}
+	return ""
+}
+
+// htmlToMarkdown Converts the basic formatting of html, e.g. of a field of an Anki note, to markdown: bold, italic
+// and struck through text, line breaks, paragraphs, lists, links, images, code, and tables. Other tags are removed
+// and only their text is kept. The source of an image is passed to media, which returns the path it is linked with.
+func htmlToMarkdown(s string, media func(src string) string) string {
+	var b strings.Builder
+	// Markers of the open lists, "-" for unordered lists and the number of the next item for ordered ones.
+	var lists []int
+	pre := false
+	// Start of the text of the open link in b and its target.
+	linkStart, href := 0, ""
+
+	// newline Ends the current line and adds blank lines until there are n line breaks.
+	newline := func(n int) {
+		text := strings.TrimRight(b.String(), " ")
+		trailing := len(text) - len(strings.TrimRight(text, "\n"))
+		b.Reset()
+		b.WriteString(text)
+		if text != "" && trailing < n {
+			b.WriteString(strings.Repeat("\n", n-trailing))
+		}
+	}
+	// emphasis Writes the markup of emphasis. Whitespace must not precede the closing markup.
+	emphasis := func(markup string, closing bool) {
+		text := b.String()
+		if closing && strings.HasSuffix(text, " ") {
+			b.Reset()
+			b.WriteString(strings.TrimRight(text, " ") + markup + " ")
+			return
+		}
+		b.WriteString(markup)
+	}
+	writeText := func(text string) {
+		if pre {
+			b.WriteString(html.UnescapeString(text))
+			return
+		}
+		text = strings.ReplaceAll(html.UnescapeString(whitespaceRegex.ReplaceAllString(text, " ")), "\u00a0", " ")
+		if current := b.String(); current == "" || strings.HasSuffix(current, "\n") ||
+			strings.HasSuffix(current, " ") {
+			text = strings.TrimLeft(text, " ")
+		}
+		b.WriteString(text)
+	}
+
+	last := 0
+	for _, m := range htmlTagRegex.FindAllStringSubmatchIndex(s, -1) {
+		writeText(s[last:m[0]])
+		last = m[1]
+		if m[4] == -1 {
+			// Comment
+			continue
+		}
+		closing := m[3] > m[2]
+		name := strings.ToLower(s[m[4]:m[5]])
+		attributes := s[m[6]:m[7]]
+		switch name {
+		case "b", "strong":
+			emphasis("**", closing)
+		case "i", "em":
+			emphasis("*", closing)
+		case "s", "strike", "del":
+			emphasis("~~", closing)
+		case "code":
+			if !pre {
+				b.WriteString("`")
+			}
+		case "br", "div", "tr":
+			newline(1)
+		case "p", "blockquote", "table":
+			newline(2)
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			// Headings can't be used in the back side of a card, they are bold instead.
+			if closing {
+				emphasis("**", true)
+				newline(2)
+			} else {
+				newline(2)
+				b.WriteString("**")
+			}
+		case "td", "th":
+			if current := b.String(); !closing && current != "" && !strings.HasSuffix(current, "\n") {
+				b.WriteString(" | ")
+			}
+		case "hr":
+			newline(2)
+			b.WriteString("***")
+			newline(2)
+		case "pre":
+			if closing {
+				newline(1)
+				b.WriteString("```")
+				newline(2)
+			} else {
+				newline(2)
+				b.WriteString("```")
+				newline(1)
+			}
+			pre = !closing
+		case "ul", "ol":
+			if closing && len(lists) > 0 {
+				lists = lists[:len(lists)-1]
+			} else if !closing {
+				// 0 marks an unordered list.
+				next := 0
+				if name == "ol" {
+					next = 1
+				}
+				lists = append(lists, next)
+			}
+			if len(lists) == 0 || !closing && len(lists) == 1 {
+				newline(2)
+			}
+		case "li":
+			if closing || len(lists) == 0 {
+				continue
+			}
+			newline(1)
+			b.WriteString(strings.Repeat("   ", len(lists)-1))
+			if next := &lists[len(lists)-1]; *next == 0 {
+				b.WriteString("- ")
+			} else {
+				fmt.Fprintf(&b, "%d. ", *next)
+				*next++
+			}
+		case "a":
+			if !closing {
+				linkStart, href = b.Len(), htmlAttribute(attributes, "href")
+			} else if href != "" && linkStart <= b.Len() {
+				text := b.String()
+				label := strings.TrimSpace(text[linkStart:])
+				b.Reset()
+				b.WriteString(text[:linkStart])
+				if label == "" || label == href {
+					fmt.Fprintf(&b, "<%s>", href)
+				} else {
+					fmt.Fprintf(&b, "[%s](%s)", label, href)
+				}
+				href = ""
+			}
+		case "img":
+			if src := htmlAttribute(attributes, "src"); src != "" {
+				fmt.Fprintf(&b, "![%s](%s)", src, strings.ReplaceAll(media(src), " ", "%20"))
+			}
+		}
+	}
+	writeText(s[last:])
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	return blankLinesRegex.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+// escapeBlockStarts Escapes the lines of a back side that would otherwise start a new card or turn the previous line
+// into a heading, e.g. `# note` or `---`.
+func escapeBlockStarts(md string) string {
+	lines := strings.Split(md, "\n")
+	inFence := false
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "```") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			lines[i] = blockStartRegex.ReplaceAllString(l, `$1\$2`)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownToHTML Renders markdown, e.g. the back side of a card, to html: paragraphs, headings, lists, blockquotes,
+// code blocks, tables, rules, and the inline markup of RenderMarkdown. Line breaks within a paragraph are kept, like
+// they are in the terminal. Html in the markdown is escaped and shown as it is.
+func markdownToHTML(md string) string {
+	var b strings.Builder
+	// Tags of the open lists, from the outermost to the innermost list, with the indentation of their items.
+	type list struct {
+		tag    string
+		indent int
+	}
+	var lists []list
+	var paragraph, quote []string
+
+	flush := func() {
+		if paragraph != nil {
+			b.WriteString("<p>" + strings.Join(paragraph, "<br>") + "</p>")
+			paragraph = nil
+		}
+		if quote != nil {
+			b.WriteString("<blockquote>" + strings.Join(quote, "<br>") + "</blockquote>")
+			quote = nil
+		}
+	}
+	closeLists := func(indent int) {
+		for len(lists) > 0 && lists[len(lists)-1].indent > indent {
+			b.WriteString("</li></" + lists[len(lists)-1].tag + ">")
+			lists = lists[:len(lists)-1]
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			flush()
+			closeLists(-1)
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>")
+			continue
+		}
+		if strings.Contains(line, "|") && i+1 < len(lines) && tableSepRegex.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-") {
+			flush()
+			closeLists(-1)
+			b.WriteString("<table><tr>")
+			for _, cell := range splitTableRow(line) {
+				b.WriteString("<th>" + inlineHTML(cell) + "</th>")
+			}
+			b.WriteString("</tr>")
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				b.WriteString("<tr>")
+				for _, cell := range splitTableRow(lines[i]) {
+					b.WriteString("<td>" + inlineHTML(cell) + "</td>")
+				}
+				b.WriteString("</tr>")
+			}
+			i--
+			b.WriteString("</table>")
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case ruleRegex.MatchString(line):
+			flush()
+			closeLists(-1)
+			b.WriteString("<hr>")
+		case headingRegex.MatchString(line):
+			flush()
+			closeLists(-1)
+			m := headingRegex.FindStringSubmatch(line)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>", len(m[1]), inlineHTML(m[2]), len(m[1]))
+		case quoteRegex.MatchString(line):
+			if paragraph != nil {
+				flush()
+			}
+			closeLists(-1)
+			quote = append(quote, inlineHTML(quoteRegex.FindStringSubmatch(line)[1]))
+		case listItemRegex.MatchString(line):
+			flush()
+			m := listItemRegex.FindStringSubmatch(line)
+			tag := "ul"
+			if unicode.IsDigit(rune(m[2][0])) {
+				tag = "ol"
+			}
+			closeLists(indent)
+			if n := len(lists); n > 0 && lists[n-1].indent == indent && lists[n-1].tag != tag {
+				closeLists(indent - 1)
+			}
+			if n := len(lists); n > 0 && lists[n-1].indent == indent {
+				b.WriteString("</li><li>")
+			} else {
+				b.WriteString("<" + tag + "><li>")
+				lists = append(lists, list{tag: tag, indent: indent})
+			}
+			b.WriteString(inlineHTML(m[3]))
+		case len(lists) > 0 && indent > 0 && paragraph == nil:
+			// Continuation of a list item
+			b.WriteString("<br>" + inlineHTML(strings.TrimSpace(line)))
+		default:
+			if quote != nil {
+				flush()
+			}
+			if indent == 0 {
+				closeLists(-1)
+			}
+			paragraph = append(paragraph, inlineHTML(strings.TrimSpace(line)))
+		}
+	}
+	flush()
+	closeLists(-1)
+	return b.String()
+}
+
+// inlineHTML Renders the inline markdown of a line to html: code spans, links, images, emphasis, and strikethrough.
+func inlineHTML(text string) string {
+	return renderInline(text, htmlInline{})
+}
+
+// htmlInline formats inline markdown as html. All text is escaped.
+type htmlInline struct{}
+
+func (htmlInline) text(s string) string {
+	return html.EscapeString(s)
+}
+
+func (htmlInline) code(s string) string {
+	return "<code>" + html.EscapeString(s) + "</code>"
+}
+
+func (htmlInline) link(label, url string) string {
+	return `<a href="` + html.EscapeString(url) + `">` + html.EscapeString(label) + "</a>"
+}
+
+func (htmlInline) image(alt, url string) string {
+	return `<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(alt) + `">`
+}
+
+func (htmlInline) emphasis(e emphasis, content string) string {
+	tag := []string{"strong", "em", "del"}[e]
+	return "<" + tag + ">" + content + "</" + tag + ">"
+}
@@ -0,0 +1,41 @@
+package internal
+
+import "testing"
+
+func TestInlineHTML(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"The ___ is the powerhouse", "The ___ is the powerhouse"},
+		{"_a_ _a_", "<em>a</em> <em>a</em>"},
+		{"*x* x", "<em>x</em> x"},
+		{"<https://example.com>", `<a href="https://example.com">https://example.com</a>`},
+		{`[a "b"](https://example.com/?a=1&b=2)`, `<a href="https://example.com/?a=1&amp;b=2">a &#34;b&#34;</a>`},
+		{"![cat](cat.png)", `<img src="cat.png" alt="cat">`},
+		{"**bold** `<code>` ~~gone~~", "<strong>bold</strong> <code>&lt;code&gt;</code> <del>gone</del>"},
+		{`1 < 2 \<b\>`, "1 &lt; 2 &lt;b&gt;"},
+	}
+	for _, tt := range tests {
+		if got := inlineHTML(tt.text); got != tt.want {
+			t.Errorf("inlineHTML(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		html, want string
+	}{
+		{"<b>bold</b> and <i>italic</i>", "**bold** and *italic*"},
+		{"line<br>break", "line\nbreak"},
+		{"<ul><li>one</li><li>two</li></ul>", "- one\n- two"},
+		{`<img src="a b.png">`, "![a b.png](a%20b.png)"},
+		{"a&nbsp;b &amp; c", "a b & c"},
+	}
+	for _, tt := range tests {
+		got := htmlToMarkdown(tt.html, func(src string) string { return src })
+		if got != tt.want {
+			t.Errorf("htmlToMarkdown(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,13 @@ package internal
 import (
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 	"time"
+	"unicode"
 )
 
 // Difficulty constants
@@ -22,15 +28,101 @@ type Card struct {
 	Back     string
 	Category string
 	Id       string
+	// Path of the file the card was read from
+	Path string
 	// Box number starts at 0
 	Box uint
 	Due time.Time
+	// Scheduling state of the SM-2 and FSRS schedulers. The fields are zero if the scheduler doesn't use them.
+	Ease       float64
+	Interval   uint
+	Stability  float64
+	Difficulty float64
+	LastReview time.Time
+	// Variant of a sub-card, e.g. `c1` for a cloze deletion (see clozeVariants) or `r` for the reverse card. Empty
+	// for ordinary cards.
+	Variant string
+	// ID of the first card of the heading when the heading was created, if that card was removed since, e.g. with
+	// its cloze deletion. See noteId.
+	note string
+	Type CardType
+	// Fields of the metadata that are unknown to this version, e.g. written by a newer version. They are kept as
+	// they are.
+	extraFields []string
+	// Whether the card's metadata only exists in memory because it was initialized for a read-only session. Its ID
+	// changes with each read until the file is written.
+	unsaved bool
+	// Index of the line of the card's heading in its file. Sub-cards of the same heading are siblings.
+	heading int
+}
+
+// noteId Returns the stable ID of the card's heading, which is the ID of its first card unless that card was
+// removed, so that its sub-cards can be exported as one note, see ExportAnki.
+func (c *Card) noteId() string {
+	if c.note != "" {
+		return c.note
+	}
+	return c.Id
+}
+
+// question Returns the markdown that is shown as the front side of the card.
+func (c *Card) question() string {
+	switch c.Variant {
+	case "":
+		return c.Front
+	case variantReverse:
+		return c.Back
+	}
+	return c.Front + "\n\n" + clozeText(c.Back, c.Variant, false)
+}
+
+// answer Returns the markdown that is shown as the back side of the card.
+func (c *Card) answer() string {
+	switch c.Variant {
+	case "":
+		return c.Back
+	case variantReverse:
+		return c.Front
+	}
+	return clozeText(c.Back, c.Variant, true)
+}
+
+// isReverseOf Returns true if the cards are the two directions of the same card.
+func (c *Card) isReverseOf(other *Card) bool {
+	return c != other && c.Path == other.Path && c.heading == other.heading &&
+		(c.Variant == variantReverse || other.Variant == variantReverse)
+}
+
+// setContent Sets the content of the card, i.e. everything that is not stored in its metadata, to the one of the
+// other card.
+func (c *Card) setContent(other Card) {
+	c.Front, c.Back, c.Category, c.Type, c.heading = other.Front, other.Back, other.Category, other.Type, other.heading
+}
+
+// title Returns the front side of the card in a single line, followed by the variant of a sub-card.
+func (c *Card) title() string {
+	if c.Variant == "" {
+		return c.Front
+	}
+	return c.Front + " [" + c.Variant + "]"
 }
 
 type File struct {
-	Path         string
-	BoxIntervals []uint
-	Cards        []Card
+	Path        string
+	FrontMatter FrontMatter
+	// Name of the scheduler as specified in the front matter. Empty for the default Leitner system.
+	SchedulerName    string
+	Scheduler        Scheduler
+	BoxIntervals     []uint
+	Multipliers      Multipliers
+	RequestRetention float64
+	// Number of characters of the IDs of new cards.
+	IdLength uint
+	Cards    []Card
+	// Problems found while parsing the file.
+	Warnings []Warning
+	// Content of the file as it was last read or written by mdfc, to tell changes of other programs apart.
+	content string
 }
 
 type Session struct {
@@ -44,64 +136,227 @@ type Session struct {
 	// are added to the study set anyway.
 	FutureDaysDue uint
 	WrapLines     uint
-	File          File
-	studyQueue    []*Card
-	currentCard   *Card
+	// Commit the changed files to their git repository after the session.
+	GitCommit bool
+	// Ask the user to type the answer before the back side is shown and compare it to the card.
+	TypeAnswer bool
+	// Open the files without locking or changing them, e.g. to list the cards.
+	ReadOnly bool
+	Files    []File
+	locks    []*fileLock
+	// Watches the files for changes during the session. Nil if the session hasn't started.
+	watcher     watcher
+	studyQueue  []*Card
+	currentCard *Card
+	// Tally of the answers given during the session.
+	results TestModeResults
+	// Full-screen interface of the session. Nil if the input or output is not a terminal.
+	terminal *Terminal
+	// State before each answer of the session, the last answer is on top.
+	undoStack []undoEntry
+}
+
+// undoEntry holds the state of the session before an answer, so that the answer can be undone.
+type undoEntry struct {
+	card     *Card
+	previous Card
+	queue    []*Card
+	results  TestModeResults
+	// Size of the card file's review history before the answer was logged.
+	historySize int64
 }
 
+// action is what the user wants to do after a card has been shown.
+type action int
+
+const (
+	actionGrade action = iota
+	actionUndo
+	actionQuit
+)
+
 type TestModeResults struct {
 	NotRemembered, Hard, Okay, Easy uint
+	// Number of answers per card type. An answer is correct unless the card was not remembered.
+	Correct, Incorrect [numberCardTypes]uint
 }
 
-// Start Starts the study session.
-func (s *Session) Start() {
-	testModeResults := TestModeResults{}
-
+// Start Starts the study session. It returns an error if the progress can't be saved, which ends the session.
+func (s *Session) Start() error {
 	s.assembleStudyQueue()
 	if len(s.studyQueue) == 0 {
 		fmt.Print("\nLooks like you don't have anything to study today.\n\n")
 		fmt.Println("If you want to learn cards that are scheduled for the next")
 		fmt.Print("few days, use the --future-days-due flag.\n\n")
 		s.printNextDueDate()
-		return
+		return nil
+	}
+
+	// Use the full-screen interface if possible. Debug output would be overwritten by it.
+	if IsInteractive() && os.Getenv("DEBUG") != "true" {
+		if t, err := OpenTerminal(); err == nil {
+			t.onExit = s.Close
+			s.terminal = t
+		}
 	}
 
 	// Start the study session.
-	for len(s.studyQueue) > 0 {
-		ScrollDownScreen()
-		card, difficulty := s.flashNextCard()
-
-		// If in test mode, don't update the metadata.
-		if s.TestMode {
-			switch difficulty {
-			case NotRemembered:
-				testModeResults.NotRemembered++
-			case Hard:
-				testModeResults.Hard++
-			case Okay:
-				testModeResults.Okay++
-			case Easy:
-				testModeResults.Easy++
+	s.watchFiles()
+	quit := false
+	var err error
+	for {
+		// Take over the changes of the files between the cards.
+		if err = s.reloadChangedFiles(); err != nil {
+			break
+		}
+		if len(s.studyQueue) == 0 {
+			// Give the user the chance to undo the last answer before the session ends.
+			if len(s.undoStack) == 0 || !s.confirmUndo() {
+				break
 			}
-		} else {
-			s.updateCard(card, difficulty)
+			if err = s.undo(); err != nil {
+				break
+			}
+			continue
+		}
+		if s.terminal == nil {
+			ScrollDownScreen()
+		}
+		entry := s.newUndoEntry()
+		start := time.Now()
+		card, difficulty, act := s.flashNextCard()
+		duration := time.Since(start)
+		if act == actionQuit {
+			quit = true
+			break
+		}
+		if act == actionUndo {
+			if err = s.undo(); err != nil {
+				break
+			}
+			continue
+		}
+		if err = s.grade(entry, card, difficulty, duration); err != nil {
+			break
 		}
 	}
 
 	// Output a user hint about (next) session.
-	ClearConsole()
+	if s.terminal != nil {
+		s.terminal.Close()
+		s.terminal = nil
+	} else {
+		ClearConsole()
+	}
+	if err != nil {
+		return err
+	}
 	if s.TestMode {
-		fmt.Printf("Not remembered:\t%d\n", testModeResults.NotRemembered)
-		fmt.Printf("Hard:\t\t%d\n", testModeResults.Hard)
-		fmt.Printf("Okay:\t\t%d\n", testModeResults.Okay)
-		fmt.Printf("Easy:\t\t%d\n", testModeResults.Easy)
+		fmt.Printf("Not remembered:\t%d\n", s.results.NotRemembered)
+		fmt.Printf("Hard:\t\t%d\n", s.results.Hard)
+		fmt.Printf("Okay:\t\t%d\n", s.results.Okay)
+		fmt.Printf("Easy:\t\t%d\n", s.results.Easy)
+		s.results.printByType()
+	}
+	if quit {
+		fmt.Println("You ended the session early. Your answers so far have been saved.")
+	} else {
+		fmt.Println("You're done with your session!")
 	}
-	fmt.Println("You're done with your session!")
 	s.printNextDueDate()
+
+	if s.GitCommit && !s.TestMode {
+		if err := s.CommitToGit(); err != nil {
+			fmt.Println("Git:", err)
+		}
+	}
+	return nil
+}
+
+// newUndoEntry Returns the state of the session before the next card of the study queue is answered.
+func (s *Session) newUndoEntry() undoEntry {
+	return undoEntry{
+		card:     s.studyQueue[0],
+		previous: *s.studyQueue[0],
+		queue:    slices.Clone(s.studyQueue),
+		results:  s.results,
+	}
+}
+
+// grade Takes the answer of the card into account: it is added to the tally and, unless in test mode, the card is
+// scheduled with the given difficulty. The state before the answer is pushed to the undo stack.
+func (s *Session) grade(entry undoEntry, c *Card, difficulty float32, duration time.Duration) error {
+	switch difficulty {
+	case NotRemembered:
+		s.results.NotRemembered++
+	case Hard:
+		s.results.Hard++
+	case Okay:
+		s.results.Okay++
+	case Easy:
+		s.results.Easy++
+	}
+	if difficulty == NotRemembered {
+		s.results.Incorrect[c.Type]++
+	} else {
+		s.results.Correct[c.Type]++
+	}
+
+	// If in test mode, don't update the metadata.
+	if !s.TestMode {
+		entry.historySize = historySize(c.Path)
+		if err := s.updateCard(c, difficulty, duration); err != nil {
+			return err
+		}
+	}
+	s.undoStack = append(s.undoStack, entry)
+	return nil
+}
+
+// printByType Prints the number of correct and incorrect answers per card type.
+func (r TestModeResults) printByType() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\nCard type\tCorrect\tIncorrect")
+	for t := CardType(0); t < numberCardTypes; t++ {
+		if r.Correct[t]+r.Incorrect[t] > 0 {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", t, r.Correct[t], r.Incorrect[t])
+		}
+	}
+	w.Flush()
+}
+
+// total Returns the number of answers.
+func (r TestModeResults) total() uint {
+	return r.NotRemembered + r.Hard + r.Okay + r.Easy
+}
+
+// cards Returns pointers to the cards of all files in the order in which they appear in the files.
+func (s *Session) cards() []*Card {
+	var cards []*Card
+	for i := range s.Files {
+		for j := range s.Files[i].Cards {
+			cards = append(cards, &s.Files[i].Cards[j])
+		}
+	}
+	return cards
+}
+
+// fileOf Returns the file the card was read from.
+func (s *Session) fileOf(c *Card) *File {
+	for i := range s.Files {
+		if s.Files[i].Path == c.Path {
+			return &s.Files[i]
+		}
+	}
+	return nil
 }
 
 func (s *Session) printNextDueDate() {
-	nextSession, err := FindClosestDate(s.File.Cards)
+	var cards []Card
+	for _, f := range s.Files {
+		cards = append(cards, f.Cards...)
+	}
+	nextSession, err := FindClosestDate(cards)
 	if err != nil {
 		fmt.Println("Please note: You still have cards due to today.")
 	} else {
@@ -122,13 +377,21 @@ func (s *Session) isDue(c Card) (due, nearDue bool) {
 	return due, nearDue
 }
 
+// reverseQueued Returns true if the other direction of the card is already in the study queue.
+func (s *Session) reverseQueued(c *Card) bool {
+	return slices.ContainsFunc(s.studyQueue, c.isReverseOf)
+}
+
 // assembleStudyQueue Assembles the cards that need to be studied according to their due date, the number of cards
 // the user wants to study, and the category. Shuffles the cards if the user doesn't want to study them sequentially.
+// Both directions of a reverse card are never studied in the same session; the other direction stays due.
 func (s *Session) assembleStudyQueue() {
 	nearDueQueue := make([]*Card, 0)
 
-	for i := 0; i < len(s.File.Cards); i++ {
-		c := &s.File.Cards[i]
+	for _, c := range s.cards() {
+		if s.reverseQueued(c) {
+			continue
+		}
 		if s.NumberCards == 0 && s.Category == "" {
 			// Study all cards.
 			s.studyQueue = append(s.studyQueue, c)
@@ -153,6 +416,9 @@ func (s *Session) assembleStudyQueue() {
 	// If the study set would be less than s.NumberCards, add cards that are due in the near future.
 	if s.NumberCards > 0 && uint(len(s.studyQueue)) < s.NumberCards {
 		for _, c := range nearDueQueue {
+			if s.reverseQueued(c) {
+				continue
+			}
 			s.studyQueue = append(s.studyQueue, c)
 			if uint(len(s.studyQueue)) == s.NumberCards {
 				break
@@ -174,9 +440,79 @@ func (s *Session) assembleStudyQueue() {
 	}
 }
 
+// gradeKeys maps the keys of the full-screen interface to the difficulty of remembering a card.
+var gradeKeys = map[rune]float32{
+	'1': NotRemembered, 'h': NotRemembered,
+	'2': Hard, 'j': Hard,
+	'3': Okay, 'k': Okay,
+	'4': Easy, 'l': Easy,
+}
+
+// cardStatus Returns the status line of the card in the full-screen interface.
+func (s *Session) cardStatus(c *Card) string {
+	var parts []string
+	if s.ShowCategory {
+		parts = append(parts, c.Category)
+	}
+	if len(s.Files) > 1 {
+		parts = append(parts, filepath.Base(c.Path))
+	}
+	parts = append(parts, fmt.Sprintf("Box %d", c.Box), "Due "+c.Due.Format("2006-01-02"))
+	return strings.Join(parts, "  |  ")
+}
+
+// undo Restores the state before the last answer: the card's metadata in memory and in the file, the study queue,
+// and the tally of the answers. The review is removed from the review history again.
+func (s *Session) undo() error {
+	if len(s.undoStack) == 0 {
+		return nil
+	}
+	e := s.undoStack[len(s.undoStack)-1]
+	s.undoStack = s.undoStack[:len(s.undoStack)-1]
+
+	// Only restore the metadata; the content of the card stays as it is.
+	previous := e.previous
+	previous.setContent(*e.card)
+	*e.card = previous
+	s.studyQueue = e.queue
+	s.results = e.results
+	if s.TestMode {
+		return nil
+	}
+	if err := s.updateCardInFile(e.card); err != nil {
+		return err
+	}
+	if err := truncateHistory(e.card.Path, e.historySize); err != nil {
+		return &IOError{Op: "write", Path: HistoryPath(e.card.Path), Err: err}
+	}
+	return nil
+}
+
+// confirmUndo Asks the user at the end of the session whether the last answer should be undone.
+func (s *Session) confirmUndo() bool {
+	if s.terminal == nil {
+		fmt.Print("\n--> That was the last card. Press enter to finish or enter 'u' to undo the last answer.")
+		return strings.TrimSpace(ReadLineInput()) == "u"
+	}
+	sc := screen{
+		done:  int(s.NumberCards),
+		total: int(s.NumberCards),
+		body:  "That was the last card.",
+		hints: "u: undo  any other key: finish",
+	}
+	draw := func() { s.terminal.Draw(sc) }
+	draw()
+	return s.terminal.ReadKey(draw).Rune == 'u'
+}
+
 // flashNextCard Shows a card's front side. The card is picked from the study queue.
 // Waits for the user to press a key to signal how difficult the card was to remember.
-func (s *Session) flashNextCard() (c *Card, difficulty float32) {
+// The user may also undo the previous answer or quit the session instead.
+func (s *Session) flashNextCard() (c *Card, difficulty float32, act action) {
+	if s.terminal != nil {
+		return s.flashNextCardInTerminal()
+	}
+
 	ClearConsole()
 	fmt.Printf("--- Cards left for today: %d / %d", len(s.studyQueue), s.NumberCards)
 
@@ -189,52 +525,275 @@ func (s *Session) flashNextCard() (c *Card, difficulty float32) {
 	}
 	fmt.Printf(" ---")
 
-	front := WrapLines(c.Front, s.WrapLines)
-	fmt.Printf("\n\n%s\n", front)
+	// The answers of multiple choice, true/false and order cards are checked like typed answers.
+	var choice *choiceQuestion
+	question := c.question()
+	if c.Type.autoGraded() {
+		choice = newChoiceQuestion(c)
+		question = choice.question()
+	}
+	checked := choice != nil || s.TypeAnswer
 
-	fmt.Print("--> Press enter to show the back side.")
-	ReadEnterInput()
+	front := RenderMarkdown(question, s.WrapLines, UseColor())
+	fmt.Printf("\n\n%s\n", front)
 
-	back := WrapLines(c.Back, s.WrapLines)
-	fmt.Printf("\n%s\n", back)
+	undoHint := ""
+	if len(s.undoStack) > 0 {
+		undoHint = " Enter 'u' to undo the last answer."
+	}
+	switch {
+	case choice != nil:
+		fmt.Printf("--> %s%s\n> ", choice.instructions(), undoHint)
+	case s.TypeAnswer:
+		fmt.Printf("--> Type your answer and press enter.%s\n> ", undoHint)
+	default:
+		fmt.Printf("--> Press enter to show the back side.%s", undoHint)
+	}
+	typed, eof := readLine()
+	for {
+		if eof {
+			return c, 0, actionQuit
+		}
+		if strings.TrimSpace(typed) == "u" && undoHint != "" {
+			return c, 0, actionUndo
+		}
+		if choice == nil || choice.parse(typed) {
+			break
+		}
+		fmt.Print("Please enter a valid answer: ")
+		typed, eof = readLine()
+	}
 
-	fmt.Println("--> How difficult was it to remember?")
+	var suggestion float32
+	switch {
+	case choice != nil:
+		var feedback string
+		feedback, suggestion = choice.feedback(s.WrapLines, UseColor())
+		fmt.Printf("\n%s", feedback)
+		if choice.explanation != "" {
+			fmt.Printf("\n%s", RenderMarkdown(choice.explanation, s.WrapLines, UseColor()))
+		}
+		fmt.Println()
+	case s.TypeAnswer:
+		back := RenderMarkdown(c.answer(), s.WrapLines, UseColor())
+		fmt.Printf("\n%s\n", back)
+		var feedback string
+		feedback, suggestion = answerFeedback(typed, c, s.WrapLines, UseColor())
+		fmt.Printf("%s\n", feedback)
+	default:
+		back := RenderMarkdown(c.answer(), s.WrapLines, UseColor())
+		fmt.Printf("\n%s\n", back)
+	}
+	if checked {
+		fmt.Println("--> Press enter to accept the suggested grade or choose another one.")
+	} else {
+		fmt.Println("--> How difficult was it to remember?")
+	}
 	fmt.Printf("--> (1) Not remembered, (2) Hard, (3) Okay, (4) Easy: ")
-	d := ReadNumberInput(1, 4)
-	switch d {
-	case 1:
-		difficulty = NotRemembered
-	case 2:
-		difficulty = Hard
-	case 3:
-		difficulty = Okay
-	case 4:
-		difficulty = Easy
-	}
-	return c, difficulty
-}
-
-// updateCard Updates the card's metadata (box and due date) according to the user's input.
-// It may also add the card back to the study queue if the answer was not remembered.
-func (s *Session) updateCard(c *Card, difficulty float32) {
+	for {
+		in, eof := readLine()
+		if eof {
+			return c, 0, actionQuit
+		}
+		in = strings.TrimSpace(in)
+		if in == "u" && undoHint != "" {
+			return c, 0, actionUndo
+		}
+		if in == "" && checked {
+			return c, suggestion, actionGrade
+		}
+		switch in {
+		case "1":
+			return c, NotRemembered, actionGrade
+		case "2":
+			return c, Hard, actionGrade
+		case "3":
+			return c, Okay, actionGrade
+		case "4":
+			return c, Easy, actionGrade
+		}
+		fmt.Print("Please enter a number: ")
+	}
+}
+
+// updateCard Updates the card's metadata (box, due date, and scheduling state) according to the user's input using
+// the scheduler of the card's file. It may also add the card back to the study queue if the answer was not remembered.
+// The review is appended to the review history of the card's file.
+func (s *Session) updateCard(c *Card, difficulty float32, duration time.Duration) error {
+	now := time.Now()
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	prevBox := c.Box
+	s.fileOf(c).Scheduler.Schedule(c, difficulty, today)
 	if difficulty == NotRemembered {
-		// Move the card to the first box, reset the due date to today, and add it back to the study queue.
-		c.Box = 0
-		y, m, d := time.Now().Date()
-		c.Due = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
 		s.studyQueue = append(s.studyQueue, c)
-	} else {
-		// Move the card to the next box but only if it is not in the last box and the answer was not Hard.
-		if difficulty != Hard && c.Box < uint(len(s.File.BoxIntervals))-1 {
-			c.Box++
+	}
+	if err := s.updateCardInFile(c); err != nil {
+		return err
+	}
+
+	err := appendReview(c.Path, Review{
+		Id:         c.Id,
+		Time:       now.Truncate(time.Second),
+		Grade:      gradeName(difficulty),
+		PrevBox:    prevBox,
+		Box:        c.Box,
+		Due:        c.Due.Format("2006-01-02"),
+		DurationMs: duration.Milliseconds(),
+	})
+	if err != nil {
+		return &IOError{Op: "write", Path: HistoryPath(c.Path), Err: err}
+	}
+	return nil
+}
+
+// flashNextCardInTerminal Shows the next card of the study queue in the full-screen interface. Space or enter flips
+// the card, and a single key press grades it. If answers are typed, enter submits the answer and accepts the
+// suggested grade afterwards.
+func (s *Session) flashNextCardInTerminal() (c *Card, difficulty float32, act action) {
+	c = s.studyQueue[0]
+	sc := screen{
+		done:   int(s.NumberCards) - len(s.studyQueue),
+		total:  int(s.NumberCards),
+		status: s.cardStatus(c),
+	}
+	if sc.done < 0 {
+		sc.done = 0
+	}
+	showBack := false
+	maxScroll := 0
+	// The answers of multiple choice, true/false and order cards are checked like typed answers.
+	var choice *choiceQuestion
+	if c.Type.autoGraded() {
+		choice = newChoiceQuestion(c)
+	}
+	typing := s.TypeAnswer && choice == nil
+	checked := choice != nil || typing
+	var typed []rune
+	var feedback string
+	var suggestion float32
+	// Line length of the last drawn screen.
+	var lineLength uint
+	draw := func() {
+		width, _ := s.terminal.Size()
+		lineLength = uint(width - 1)
+		if s.WrapLines > 0 && s.WrapLines < lineLength {
+			lineLength = s.WrapLines
+		}
+		color := UseColor()
+		switch {
+		case choice != nil && showBack:
+			sc.body = RenderMarkdown(c.Front, lineLength, color)
+		case choice != nil:
+			sc.body = RenderMarkdown(choice.question(), lineLength, color)
+		default:
+			sc.body = RenderMarkdown(c.question(), lineLength, color)
 		}
-		y, m, d := time.Now().Date()
-		daysInFuture := int(float32(s.File.BoxIntervals[c.Box]) * difficulty)
-		if daysInFuture == 0 {
-			// Since the int conversion floors the number, make sure the card is due at least one day in the future.
-			daysInFuture = 1
+		if typing {
+			sc.body += "\n> " + string(typed)
+			if !showBack {
+				sc.body += "█"
+			}
+			sc.body += "\n"
+		}
+		if showBack {
+			if checked {
+				sc.body += "\n" + feedback
+			}
+			back := c.answer()
+			if choice != nil {
+				back = choice.explanation
+			}
+			if back != "" {
+				sc.body += "\n" + strings.Repeat("─", int(lineLength)) + "\n\n" + RenderMarkdown(back, lineLength, color)
+			}
+			sc.hints = "1/h: not remembered  2/j: hard  3/k: okay  4/l: easy"
+			if checked {
+				sc.hints = "enter: accept  " + sc.hints
+			}
+		} else if choice != nil {
+			sc.hints = choice.keyHints()
+		} else if typing {
+			sc.hints = "enter: check answer"
+		} else {
+			sc.hints = "space: show back side"
+		}
+		switch {
+		case checked && !showBack:
+			// Letters are part of the answer while answering.
+			if len(s.undoStack) > 0 {
+				sc.hints += "  ctrl-z: undo"
+			}
+			sc.hints += "  esc: quit"
+		default:
+			if len(s.undoStack) > 0 {
+				sc.hints += "  u: undo"
+			}
+			sc.hints += "  q: quit"
+		}
+		maxScroll = s.terminal.Draw(sc)
+	}
+
+	for {
+		draw()
+		k := s.terminal.ReadKey(draw)
+		if checked && !showBack {
+			switch {
+			case k.Special == KeyEscape, k.Special == KeyEOF:
+				return c, 0, actionQuit
+			case k.Special == KeyUndo && len(s.undoStack) > 0:
+				return c, 0, actionUndo
+			case choice != nil:
+				submit := false
+				switch k.Special {
+				case KeyEnter:
+					submit = choice.complete()
+				case KeyBackspace:
+					choice.removeLast()
+				case KeyNone:
+					submit = choice.press(k.Rune)
+				}
+				if submit {
+					showBack = true
+					feedback, suggestion = choice.feedback(lineLength, UseColor())
+				}
+			case k.Special == KeyEnter:
+				showBack = true
+				feedback, suggestion = answerFeedback(string(typed), c, lineLength, UseColor())
+			case k.Special == KeyBackspace:
+				if len(typed) > 0 {
+					typed = typed[:len(typed)-1]
+				}
+			case k.Rune != 0 && unicode.IsPrint(k.Rune):
+				typed = append(typed, k.Rune)
+			}
+			if k.Special != KeyUp && k.Special != KeyDown {
+				continue
+			}
+		}
+		switch {
+		case k.Special == KeyUp:
+			if sc.scroll > 0 {
+				sc.scroll--
+			}
+		case k.Special == KeyDown:
+			if sc.scroll < maxScroll {
+				sc.scroll++
+			}
+		case k.Rune == 'q', k.Special == KeyEscape, k.Special == KeyEOF:
+			return c, 0, actionQuit
+		case k.Rune == 'u' && len(s.undoStack) > 0:
+			return c, 0, actionUndo
+		case !showBack && (k.Rune == ' ' || k.Special == KeyEnter):
+			showBack = true
+		case showBack && checked && k.Special == KeyEnter:
+			s.studyQueue = s.studyQueue[1:]
+			return c, suggestion, actionGrade
+		case showBack:
+			if d, ok := gradeKeys[k.Rune]; ok {
+				s.studyQueue = s.studyQueue[1:]
+				return c, d, actionGrade
+			}
 		}
-		c.Due = time.Date(y, m, d, 0, 0, 0, 0, time.UTC).AddDate(0, 0, daysInFuture)
 	}
-	s.updateCardInFile(c)
 }
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// answerTestCard Answers the next card of the study queue with the given difficulty like the session does.
+func answerTestCard(t *testing.T, s *Session, difficulty float32) *Card {
+	t.Helper()
+	entry := s.newUndoEntry()
+	c := s.studyQueue[0]
+	s.studyQueue = s.studyQueue[1:]
+	if err := s.grade(entry, c, difficulty, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// queueIds Returns the IDs of the cards of the study queue.
+func queueIds(s *Session) []string {
+	ids := make([]string, len(s.studyQueue))
+	for i, c := range s.studyQueue {
+		ids[i] = c.Id
+	}
+	return ids
+}
+
+func TestUndo(t *testing.T) {
+	md := "# C\n\n## Q1 <!--mdfc:2;abcd1234;2;2023-03-01-->\n\nA1\n\n## Q2 <!--mdfc:2;efgh5678;0;2023-03-01-->\n\nA2\n"
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, md)
+	s := &Session{Sequential: true}
+	if err := s.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.assembleStudyQueue()
+	readTest := func(path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	check := func(step, wantFile string, wantReviews int, wantQueue ...string) {
+		t.Helper()
+		if got := readTest(path); got != wantFile {
+			t.Errorf("%s: file = %q, want %q", step, got, wantFile)
+		}
+		history := readTest(HistoryPath(path))
+		if got := strings.Count(history, "\n"); got != wantReviews {
+			t.Errorf("%s: history has %d reviews, want %d: %q", step, got, wantReviews, history)
+		}
+		if got := queueIds(s); !slices.Equal(got, wantQueue) {
+			t.Errorf("%s: queue = %v, want %v", step, got, wantQueue)
+		}
+	}
+	check("before", md, 0, "abcd1234", "efgh5678")
+
+	// The card that isn't remembered is moved to the first box and added to the end of the queue again.
+	c := answerTestCard(t, s, NotRemembered)
+	if c.Id != "abcd1234" || c.Box != 0 {
+		t.Fatalf("unexpected card %+v", c)
+	}
+	today := time.Now().Format("2006-01-02")
+	first := strings.Replace(md, "abcd1234;2;2023-03-01", "abcd1234;0;"+today, 1)
+	check("first answer", first, 1, "efgh5678", "abcd1234")
+	answerTestCard(t, s, Okay)
+	second := strings.Replace(first, "efgh5678;0;2023-03-01", "efgh5678;1;"+time.Now().AddDate(0, 0, 1).
+		Format("2006-01-02"), 1)
+	check("second answer", second, 2, "abcd1234")
+	if s.results.NotRemembered != 1 || s.results.Okay != 1 {
+		t.Errorf("unexpected results %+v", s.results)
+	}
+
+	if err := s.undo(); err != nil {
+		t.Fatal(err)
+	}
+	check("first undo", first, 1, "efgh5678", "abcd1234")
+	if err := s.undo(); err != nil {
+		t.Fatal(err)
+	}
+	// The history is removed since it was empty before.
+	check("second undo", md, 0, "abcd1234", "efgh5678")
+	if _, err := os.Stat(HistoryPath(path)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("history still exists: %v", err)
+	}
+	if s.results != (TestModeResults{}) || s.Files[0].Cards[0].Box != 2 || len(s.undoStack) != 0 {
+		t.Errorf("state isn't restored: results %+v, box %d", s.results, s.Files[0].Cards[0].Box)
+	}
+	// Nothing is left to undo.
+	if err := s.undo(); err != nil {
+		t.Fatal(err)
+	}
+	check("third undo", md, 0, "abcd1234", "efgh5678")
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFrontLength is the number of characters of a question above which Lint suggests to split the card.
+const maxFrontLength = 150
+
+var (
+	// idRegex matches a valid card ID as it is generated by newMetadata.
+	idRegex = regexp.MustCompile(`^[0-9A-Za-z]{4,32}$`)
+	// commentRegex matches an html comment with the whitespace before it.
+	commentRegex = regexp.MustCompile(`\s*<!--.*?-->`)
+)
+
+// fixableRules are the rules of the problems that Lint repairs if fix is true.
+var fixableRules = []string{"duplicate-id", "malformed-id", "malformed-metadata", "invalid-metadata",
+	"orphaned-metadata"}
+
+// Issue is a problem of a deck file found by Lint.
+type Issue struct {
+	Warning
+	// Whether Lint repairs the problem if fix is true.
+	Fixable bool `json:"fixable"`
+	Fixed   bool `json:"fixed"`
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("%s:%d: %s [%s]", i.Path, i.Line, i.Message, i.Rule)
+	if i.Fixed {
+		s += " (fixed)"
+	}
+	return s
+}
+
+// Lint Checks a deck file for problems without studying it. Besides the warnings of reading the file, e.g. boxes
+// beyond the last box of the Leitner system, it reports cards before the first category, malformed IDs, duplicate
+// questions, and long questions. If fix is true, the problems of fixableRules are repaired in the file; all other
+// problems need to be fixed by hand.
+func Lint(path string, fix bool) ([]Issue, error) {
+	file, err := readFile(path, false)
+	if err != nil {
+		return nil, err
+	}
+	lines, endings := splitLines(file.content)
+
+	issues := make([]Issue, 0, len(file.Warnings))
+	add := func(w Warning) {
+		issues = append(issues, Issue{Warning: w, Fixable: slices.Contains(fixableRules, w.Rule)})
+	}
+	issue := func(line int, rule, format string, a ...any) {
+		add(Warning{Path: path, Line: line + 1, Rule: rule, Message: fmt.Sprintf(format, a...)})
+	}
+	for _, w := range file.Warnings {
+		add(w)
+	}
+
+	_, leitner := file.Scheduler.(Leitner)
+	lastBox := len(file.BoxIntervals) - 1
+	// Line of the first heading of each question.
+	questions := make(map[string]int)
+	for i, c := range file.Cards {
+		// Sub-cards share the heading.
+		if i > 0 && file.Cards[i-1].heading == c.heading {
+			continue
+		}
+		if c.Category == "" {
+			issue(c.heading, "no-category", "card before the first category, i.e. a heading like '# category'")
+		}
+		question := strings.ToLower(c.Front)
+		if first, ok := questions[question]; ok {
+			issue(c.heading, "duplicate-question", "same question as in line %d", first+1)
+		} else {
+			questions[question] = c.heading
+		}
+		if n := utf8.RuneCountInString(c.Front); n > maxFrontLength {
+			issue(c.heading, "long-front", "question is %d characters long, consider splitting the card", n)
+		}
+		for _, m := range metadataRegex.FindAllStringSubmatch(lines[c.heading], -1) {
+			if !idRegex.MatchString(m[1]) {
+				issue(c.heading, "malformed-id", "malformed card ID %q, a new ID is generated", m[1])
+			}
+		}
+	}
+	slices.SortStableFunc(issues, func(a, b Issue) int { return a.Line - b.Line })
+
+	if !fix || !slices.ContainsFunc(issues, func(i Issue) bool { return i.Fixable }) {
+		return issues, nil
+	}
+	lock, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.unlock()
+
+	var removed []int
+	for _, i := range issues {
+		line := i.Line - 1
+		switch i.Rule {
+		case "orphaned-metadata":
+			lines[line] = metadataRegex.ReplaceAllString(lines[line], "")
+			if strings.TrimSpace(lines[line]) == "" && !slices.Contains(removed, line) {
+				removed = append(removed, line)
+			}
+		case "malformed-id", "malformed-metadata", "invalid-metadata":
+			lines[line] = repairMetadata(lines[line], leitner, uint(lastBox), file.IdLength)
+		}
+	}
+	// Remove the lines from the end, so that the indices stay valid.
+	slices.Sort(removed)
+	for j := len(removed) - 1; j >= 0; j-- {
+		lines = slices.Delete(lines, removed[j], removed[j]+1)
+		endings = slices.Delete(endings, removed[j], removed[j]+1)
+	}
+	if err = writeFileAtomic(path, []byte(joinLines(lines, endings))); err != nil {
+		return nil, &IOError{Op: "write", Path: path, Err: err}
+	}
+	// Reading the file again generates new IDs for duplicates and new metadata for the removed comments.
+	if _, err = readFile(path, true); err != nil {
+		return nil, err
+	}
+
+	// A problem is fixed if the repaired file doesn't have it anymore, in the line it moved to.
+	remaining, err := Lint(path, false)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range issues {
+		line := i.Line - 1
+		if !i.Fixable || slices.Contains(removed, line) {
+			issues[j].Fixed = i.Fixable
+			continue
+		}
+		for _, r := range removed {
+			if r < i.Line-1 {
+				line--
+			}
+		}
+		issues[j].Fixed = !slices.ContainsFunc(remaining, func(r Issue) bool {
+			return r.Rule == i.Rule && r.Line == line+1
+		})
+	}
+	return issues, nil
+}
+
+// repairMetadata Repairs the metadata comments of a card's heading: malformed comments are removed, malformed IDs are
+// replaced by new ones with idLength characters, and invalid values are replaced like they are when the file is read.
+// Comments without problems are kept as they are.
+func repairMetadata(line string, leitner bool, lastBox, idLength uint) string {
+	line = commentRegex.ReplaceAllStringFunc(line, func(comment string) string {
+		if strings.Contains(comment, ";") && !metadataRegex.MatchString(comment) {
+			return ""
+		}
+		return comment
+	})
+	return metadataRegex.ReplaceAllStringFunc(line, func(metadata string) string {
+		changed := false
+		if id, _, _, _ := getMetadata(metadata); !idRegex.MatchString(id) {
+			metadata, _ = generateNewId(metadata, idLength)
+			changed = true
+		}
+		cards, problems := getCardsFromLine(metadata, "", 0)
+		c := cards[0]
+		if leitner && c.Box > lastBox {
+			c.Box = lastBox
+			changed = true
+		}
+		if !changed && len(problems) == 0 {
+			return metadata
+		}
+		return formatMetadata(&c)
+	})
+}
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLintFix(t *testing.T) {
+	md := "# C\n\n" +
+		"## Q1 <!--mdfc:2;abcd1234;1;2023-03-01-->\n\nA1\n<!--mdfc:2;orphan12;0;2023-03-01-->\n\n" +
+		"## Q2 <!--mdfc:2;abcd1234;2;2023-03-01-->\n\nA2\n\n" +
+		"## Q3 <!--mdfc:2;ab!d;1;2023-03-01-->\n\nA3\n\n" +
+		"## Q4 <!--mdfc:2;efgh5678;99;2023-03-01-->\n\nA4\n\n" +
+		"## Q4 <!--mdfc:2;ijkl9012;1;2023-03-01-->\n\nA4\n"
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, md)
+	issues, err := Lint(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		rule  string
+		line  int
+		fixed bool
+	}
+	want := []result{{"orphaned-metadata", 6, true}, {"duplicate-id", 8, true}, {"malformed-id", 12, true},
+		{"invalid-metadata", 16, true}, {"duplicate-question", 20, false}}
+	if len(issues) != len(want) {
+		t.Fatalf("Lint() = %v, want %d issues", issues, len(want))
+	}
+	for j, i := range issues {
+		if got := (result{i.Rule, i.Line, i.Fixed}); got != want[j] {
+			t.Errorf("issue %d = %+v, want %+v", j, got, want[j])
+		}
+	}
+
+	// Only the problem that can't be fixed is left, one line further up since the orphaned comment was removed.
+	remaining, err := Lint(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].Rule != "duplicate-question" || remaining[0].Line != 19 {
+		t.Errorf("issues after fixing = %v", remaining)
+	}
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// PrintCards Prints a table of the cards in the session's category with their ID, box and due date. The file of each
+// card is only printed if the session contains more than one file.
+func (s *Session) PrintCards() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(s.Files) > 1 {
+		fmt.Fprint(w, "File\t")
+	}
+	fmt.Fprintln(w, "Category\tId\tBox\tDue\tFront")
+	for _, c := range s.cards() {
+		if !CompareCategory(c.Category, s.Category) {
+			continue
+		}
+		if len(s.Files) > 1 {
+			fmt.Fprintf(w, "%s\t", filepath.Base(c.Path))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", c.Category, c.Id, c.Box, c.Due.Format("2006-01-02"), c.title())
+	}
+	w.Flush()
+}
+
+// Report is the JSON output of `mdfc list --json` and `mdfc due --json`, e.g. for scripts and status bars. The names
+// of its fields are part of the command line interface: fields may be added, but are neither renamed nor removed.
+type Report struct {
+	// Number of days after today within which a card is upcoming, see Session.FutureDaysDue. Only `mdfc due` sets
+	// it; it is omitted if it is 0, e.g. for `mdfc list`, which counts no upcoming cards.
+	Days uint `json:"days,omitempty"`
+	// Number of cards that are due today, including the ones whose due date was missed, and of upcoming cards.
+	DueToday int `json:"dueToday"`
+	Upcoming int `json:"upcoming"`
+	// Earliest due date after today in the format YYYY-MM-DD. Empty if no card is due after today.
+	NextDue string `json:"nextDue,omitempty"`
+	// Number of cards per box, starting at box 0.
+	Boxes []int `json:"boxes"`
+	// Number of cards per file and category in the order in which they appear in the files.
+	Categories []CategoryReport `json:"categories"`
+	Cards      []CardReport     `json:"cards,omitempty"`
+}
+
+// CategoryReport holds the number of cards of a category of a file.
+type CategoryReport struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Cards    int    `json:"cards"`
+	DueToday int    `json:"dueToday"`
+	Upcoming int    `json:"upcoming"`
+}
+
+// CardReport is a card of a Report. Sub-cards, e.g. the deletions of a cloze card, are cards of their own. The ID of a
+// card without metadata in its file is empty and Unsaved is true: the listing doesn't write the file, so the ID would
+// be a different random one on each run.
+type CardReport struct {
+	Path string `json:"path"`
+	// Line number of the card's heading, starting at 1.
+	Line     int    `json:"line"`
+	Category string `json:"category"`
+	Id       string `json:"id"`
+	Unsaved  bool   `json:"unsaved,omitempty"`
+	Variant  string `json:"variant,omitempty"`
+	Type     string `json:"type"`
+	Front    string `json:"front"`
+	Back     string `json:"back"`
+	Box      uint   `json:"box"`
+	Due      string `json:"due"`
+	// Scheduling state of the SM-2 and FSRS schedulers, see Card.
+	Ease       float64 `json:"ease,omitempty"`
+	Interval   uint    `json:"interval,omitempty"`
+	Stability  float64 `json:"stability,omitempty"`
+	Difficulty float64 `json:"difficulty,omitempty"`
+	LastReview string  `json:"lastReview,omitempty"`
+}
+
+// cardTypeKeys are the names of the card types in a Report.
+var cardTypeKeys = [numberCardTypes]string{"basic", "cloze", "multipleChoice", "trueFalse", "order"}
+
+// Report Returns the report of the cards in the session's category. A card is upcoming if it is due within the next
+// Session.FutureDaysDue days (see isDue). The cards themselves are only part of the report if withCards is true.
+func (s *Session) Report(withCards bool) Report {
+	r := Report{Days: s.FutureDaysDue, Boxes: []int{}, Categories: []CategoryReport{}}
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	for _, c := range s.cards() {
+		if !CompareCategory(c.Category, s.Category) {
+			continue
+		}
+		i := slices.IndexFunc(r.Categories, func(cr CategoryReport) bool {
+			return cr.Path == c.Path && cr.Category == c.Category
+		})
+		if i == -1 {
+			r.Categories = append(r.Categories, CategoryReport{Path: c.Path, Category: c.Category})
+			i = len(r.Categories) - 1
+		}
+		category := &r.Categories[i]
+		category.Cards++
+		due, nearDue := s.isDue(*c)
+		if due {
+			category.DueToday++
+			r.DueToday++
+		} else if nearDue {
+			category.Upcoming++
+			r.Upcoming++
+		}
+		if c.Due.After(today) && (r.NextDue == "" || c.Due.Format("2006-01-02") < r.NextDue) {
+			r.NextDue = c.Due.Format("2006-01-02")
+		}
+		for uint(len(r.Boxes)) <= c.Box {
+			r.Boxes = append(r.Boxes, 0)
+		}
+		r.Boxes[c.Box]++
+
+		if withCards {
+			card := CardReport{Path: c.Path, Line: c.heading + 1, Category: c.Category, Id: c.Id, Variant: c.Variant,
+				Type: cardTypeKeys[c.Type], Front: c.Front, Back: c.Back, Box: c.Box, Due: c.Due.Format("2006-01-02"),
+				Ease: c.Ease, Interval: c.Interval, Stability: c.Stability, Difficulty: c.Difficulty}
+			if !c.LastReview.IsZero() {
+				card.LastReview = c.LastReview.Format("2006-01-02")
+			}
+			if c.unsaved {
+				card.Id, card.Unsaved = "", true
+			}
+			r.Cards = append(r.Cards, card)
+		}
+	}
+	return r
+}
+
+// PrintDueCounts Prints a table of the number of cards that are due today and within the next Session.FutureDaysDue
+// days per category, followed by the total. The file of each category is only printed if the session contains more
+// than one file.
+func (s *Session) PrintDueCounts() {
+	r := s.Report(false)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(s.Files) > 1 {
+		fmt.Fprint(w, "File\t")
+	}
+	fmt.Fprintf(w, "Category\tToday\tNext %d days\n", r.Days)
+	for _, c := range r.Categories {
+		if len(s.Files) > 1 {
+			fmt.Fprintf(w, "%s\t", filepath.Base(c.Path))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\n", c.Category, c.DueToday, c.Upcoming)
+	}
+	if len(s.Files) > 1 {
+		fmt.Fprint(w, "\t")
+	}
+	fmt.Fprintf(w, "Total\t%d\t%d\n", r.DueToday, r.Upcoming)
+	w.Flush()
+}
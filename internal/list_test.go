@@ -0,0 +1,31 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, "# C\n\n## Saved <!--mdfc:2;abcd1234;1;2023-03-01-->\n\nA\n\n## New {reverse}\n\nB\n")
+	s := &Session{ReadOnly: true}
+	if err := s.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	r := s.Report(true)
+	if r.Days != 0 || r.DueToday != 3 || len(r.Categories) != 1 || r.Categories[0].Cards != 3 {
+		t.Errorf("unexpected report %+v", r)
+	}
+	if len(r.Cards) != 3 {
+		t.Fatalf("got %d cards, want 3", len(r.Cards))
+	}
+	if c := r.Cards[0]; c.Id != "abcd1234" || c.Unsaved || c.Box != 1 || c.Due != "2023-03-01" || c.Line != 3 {
+		t.Errorf("unexpected saved card %+v", c)
+	}
+	// The IDs of the new card and its reverse card are generated on each read, so they are not reported.
+	for _, c := range r.Cards[1:] {
+		if c.Id != "" || !c.Unsaved {
+			t.Errorf("card %q: id = %q, unsaved = %v, want an empty id of an unsaved card", c.Front, c.Id, c.Unsaved)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrLocked is returned if a deck file is locked by another mdfc process, e.g. because it is studied in another
+// terminal.
+var ErrLocked = errors.New("file is in use by another mdfc process")
+
+// lockPath Returns the path of the lock file of the given deck file. The lock file only exists while the deck is
+// opened by mdfc.
+func lockPath(path string) string {
+	return strings.TrimSuffix(path, ".md") + ".lock"
+}
+
+// Close Stops watching the session's files and releases their locks. It must be called when the session is over.
+func (s *Session) Close() {
+	if s.watcher != nil {
+		s.watcher.close()
+		s.watcher = nil
+	}
+	for _, l := range s.locks {
+		l.unlock()
+	}
+	s.locks = nil
+}
@@ -0,0 +1,44 @@
+//go:build !unix
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// fileLock is an advisory lock of a deck file. It is held by creating the lock file next to the deck. If mdfc
+// crashes, the lock file has to be removed by hand.
+type fileLock struct {
+	path string
+}
+
+// lockFile Acquires the lock of the given deck file without waiting. It returns ErrLocked if another process holds
+// the lock.
+func lockFile(path string) (*fileLock, error) {
+	// A deck that is opened through a symlink has the same lock.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	lp := lockPath(path)
+	f, err := os.OpenFile(lp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w: %s (remove %s if no other mdfc process is running)", ErrLocked, path, lp)
+	}
+	if err != nil {
+		return nil, &IOError{Op: "lock", Path: lp, Err: err}
+	}
+	fmt.Fprintln(f, os.Getpid())
+	if err = f.Close(); err != nil {
+		os.Remove(lp)
+		return nil, &IOError{Op: "lock", Path: lp, Err: err}
+	}
+	return &fileLock{path: lp}, nil
+}
+
+// unlock Removes the lock file.
+func (l *fileLock) unlock() error {
+	return os.Remove(l.path)
+}
@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.md")
+	lock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = lockFile(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second lockFile() = %v, want ErrLocked", err)
+	}
+	if err = lock.unlock(); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() after unlocking = %v", err)
+	}
+	lock.unlock()
+
+	// The lock file can't be created in a missing directory.
+	missing := filepath.Join(t.TempDir(), "missing", "deck.md")
+	var ioErr *IOError
+	if _, err = lockFile(missing); !errors.As(err, &ioErr) || ioErr.Op != "lock" || !errors.Is(err, ErrIO) {
+		t.Errorf("lockFile() in a missing directory = %v, want an IOError", err)
+	}
+}
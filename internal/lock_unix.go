@@ -0,0 +1,63 @@
+//go:build unix
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileLock is an advisory lock of a deck file. It is held with flock on the lock file next to the deck, so it is
+// released by the operating system if mdfc crashes.
+type fileLock struct {
+	path string
+	f    *os.File
+}
+
+// lockFile Acquires the lock of the given deck file without waiting. It returns ErrLocked if another process holds
+// the lock.
+func lockFile(path string) (*fileLock, error) {
+	// A deck that is opened through a symlink has the same lock.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	lp := lockPath(path)
+	for {
+		f, err := os.OpenFile(lp, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, &IOError{Op: "lock", Path: lp, Err: err}
+		}
+		if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+			}
+			return nil, &IOError{Op: "lock", Path: lp, Err: err}
+		}
+
+		// The lock file is removed when the lock is released. If that happened between opening and locking the file,
+		// the lock is held on a removed file and has to be acquired again.
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, &IOError{Op: "lock", Path: lp, Err: err}
+		}
+		current, err := os.Stat(lp)
+		if err == nil && os.SameFile(info, current) {
+			return &fileLock{path: lp, f: f}, nil
+		}
+		f.Close()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, &IOError{Op: "lock", Path: lp, Err: err}
+		}
+	}
+}
+
+// unlock Removes the lock file and releases the lock.
+func (l *fileLock) unlock() error {
+	os.Remove(l.path)
+	return l.f.Close()
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Warning is a problem in a markdown file that doesn't prevent it from being studied, e.g. a card without a back
+// side.
+type Warning struct {
+	Path string `json:"path"`
+	// Line number, starting at 1.
+	Line int `json:"line"`
+	// Short name of the kind of problem, e.g. `empty-card`.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d: warning: %s", w.Path, w.Line, w.Message)
+}
+
+// section is a heading of a markdown file and the lines up to the next heading. A first-level heading starts a
+// category, all other headings start a card.
+type section struct {
+	level int
+	// Index of the line that holds the heading's text.
+	line int
+	// Indices of the first line after the heading (after the underline of a setext heading) and of the first line
+	// after the section.
+	start, end int
+}
+
+var (
+	atxHeadingRegex    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+|$)`)
+	atxClosingRegex    = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	setextRegex        = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematicBreakRegex = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	// A code fence may also start a list item.
+	codeFenceRegex        = regexp.MustCompile("^ *(?:(?:[-+*]|\\d+[.)]) +)?(`{3,}|~{3,})(.*)$")
+	blockquoteRegex       = regexp.MustCompile(`^ {0,3}>`)
+	listStartRegex        = regexp.MustCompile(`^ *(?:[-+*]|\d+[.)])(?:[ \t]|$)`)
+	htmlRawStartRegex     = regexp.MustCompile(`(?i)^ {0,3}<(script|pre|style|textarea)(?:[\s>]|$)`)
+	htmlCommentStartRegex = regexp.MustCompile(`^ {0,3}<!--`)
+	htmlBlockStartRegex   = regexp.MustCompile(`^ {0,3}</?[a-zA-Z][a-zA-Z0-9-]*(?:[\s/>]|$)`)
+)
+
+// lineKind is the kind of block the previous line belongs to. It decides how the next line is interpreted.
+type lineKind int
+
+const (
+	kindBlank lineKind = iota
+	kindParagraph
+	// Lines of list items and blockquotes. Text that follows them is a lazy continuation.
+	kindContainer
+	kindOther
+)
+
+// parseMarkdown Parses the block structure of the markdown lines starting at the given line and returns the sections
+// of the headings. Headings are only recognized outside of code blocks, HTML blocks, blockquotes, and list items. The
+// warnings hold problems of the structure, e.g. an unclosed code fence.
+func parseMarkdown(path string, lines []string, start int) (sections []section, warnings []Warning) {
+	warn := func(line int, rule, format string, a ...any) {
+		warnings = append(warnings, Warning{Path: path, Line: line + 1, Rule: rule, Message: fmt.Sprintf(format, a...)})
+	}
+	addSection := func(level, line, start int) {
+		if n := len(sections); n > 0 && sections[n-1].end == -1 {
+			sections[n-1].end = line
+		}
+		sections = append(sections, section{level: level, line: line, start: start, end: -1})
+	}
+
+	prev := kindBlank
+	paragraphStart, paragraphLines := 0, 0
+	inList := false
+	// Lines with card metadata. Metadata is only valid in the heading of a card.
+	var metadataLines []int
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		// Fenced code block
+		if m := codeFenceRegex.FindStringSubmatch(line); m != nil && !(m[1][0] == '`' && strings.Contains(m[2], "`")) {
+			fence := m[1]
+			end := i + 1
+			for ; end < len(lines); end++ {
+				closing := strings.TrimSpace(lines[end])
+				if strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+					break
+				}
+			}
+			if end == len(lines) {
+				warn(i, "unclosed-fence", "unclosed code fence, the rest of the file is code")
+			}
+			i = end
+			prev = kindOther
+			continue
+		}
+
+		// HTML block
+		var htmlEnd func(string) bool
+		switch {
+		case htmlRawStartRegex.MatchString(line):
+			tag := strings.ToLower(htmlRawStartRegex.FindStringSubmatch(line)[1])
+			htmlEnd = func(l string) bool { return strings.Contains(strings.ToLower(l), "</"+tag+">") }
+		case htmlCommentStartRegex.MatchString(line):
+			htmlEnd = func(l string) bool { return strings.Contains(l, "-->") }
+		case htmlBlockStartRegex.MatchString(line) && prev != kindParagraph:
+			htmlEnd = func(l string) bool { return strings.TrimSpace(l) == "" }
+		}
+		if htmlEnd != nil {
+			end := i
+			for ; end < len(lines) && (end == i || !htmlEnd(lines[end-1])); end++ {
+				if end > i && atxHeadingRegex.MatchString(lines[end]) {
+					warn(end, "html-heading", "heading inside an HTML block is not a card; separate it with a blank line")
+				}
+				if metadataRegex.MatchString(lines[end]) {
+					metadataLines = append(metadataLines, end)
+				}
+			}
+			if end == len(lines) && !htmlEnd(lines[end-1]) && !htmlEnd("") {
+				warn(i, "unclosed-html", "unclosed HTML block, the rest of the file is HTML")
+			}
+			i = end - 1
+			prev = kindOther
+			continue
+		}
+
+		if metadataRegex.MatchString(line) {
+			metadataLines = append(metadataLines, i)
+		}
+		switch {
+		case trimmed == "":
+			prev = kindBlank
+		case atxHeadingRegex.MatchString(line) && !(inList && indent > 0):
+			level := len(atxHeadingRegex.FindStringSubmatch(line)[1])
+			addSection(level, i, i+1)
+			prev, inList = kindOther, false
+		case setextRegex.MatchString(line) && prev == kindParagraph:
+			if paragraphLines > 1 {
+				warn(i, "setext-heading",
+					"setext heading with several lines is not supported, use a heading like '## question' instead")
+				if trimmed[0] == '-' {
+					prev = kindOther
+				}
+				continue
+			}
+			level := 2
+			if trimmed[0] == '=' {
+				level = 1
+			}
+			addSection(level, paragraphStart, i+1)
+			prev = kindOther
+		case thematicBreakRegex.MatchString(line):
+			prev, inList = kindOther, false
+		case blockquoteRegex.MatchString(line):
+			prev = kindContainer
+		case listStartRegex.MatchString(line):
+			prev, inList = kindContainer, true
+		case prev == kindParagraph:
+			paragraphLines++
+		case prev == kindContainer:
+			// Lazy continuation of a list item or blockquote.
+		case indent >= 4:
+			// Indented code block or content of a list item.
+			prev = kindOther
+		case inList && indent > 0:
+			// Paragraph of a list item; it can't be a setext heading of the file.
+			prev = kindOther
+		default:
+			prev, inList = kindParagraph, false
+			paragraphStart, paragraphLines = i, 1
+		}
+	}
+	if n := len(sections); n > 0 && sections[n-1].end == -1 {
+		sections[n-1].end = len(lines)
+	}
+	for _, l := range metadataLines {
+		if !slices.ContainsFunc(sections, func(s section) bool { return s.line == l && s.level > 1 }) {
+			warn(l, "orphaned-metadata", "card metadata outside of a card heading is ignored")
+		}
+	}
+	slices.SortStableFunc(warnings, func(a, b Warning) int { return a.Line - b.Line })
+	return sections, warnings
+}
+
+// splitLines splits the content of a file into lines without their line endings. The line endings ("\n" or "\r\n")
+// are returned separately, so that the file can be written back byte-for-byte; the line ending of the last line is
+// empty if the file doesn't end with a newline.
+func splitLines(data string) (lines, endings []string) {
+	for len(data) > 0 {
+		i := strings.IndexByte(data, '\n')
+		if i == -1 {
+			lines = append(lines, data)
+			endings = append(endings, "")
+			break
+		}
+		line, ending := data[:i], "\n"
+		if strings.HasSuffix(line, "\r") {
+			line, ending = line[:len(line)-1], "\r\n"
+		}
+		lines = append(lines, line)
+		endings = append(endings, ending)
+		data = data[i+1:]
+	}
+	return lines, endings
+}
+
+// joinLines joins the lines with their line endings, see splitLines.
+func joinLines(lines, endings []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		b.WriteString(line)
+		b.WriteString(endings[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	md := strings.Join([]string{
+		"# Category",              // 0
+		"",                        // 1
+		"## Card in a fence",      // 2
+		"",                        // 3
+		"```bash",                 // 4
+		"## not a card",           // 5
+		"# not a category",        // 6
+		"```",                     // 7
+		"",                        // 8
+		"Setext card",             // 9
+		"-----------",             // 10
+		"",                        // 11
+		"> ## not a card",         // 12
+		"- item",                  // 13
+		"  ## not a card",         // 14
+		"",                        // 15
+		"<div>",                   // 16
+		"## not a card",           // 17
+		"</div>",                  // 18
+		"",                        // 19
+		"    ## indented code",    // 20
+		"",                        // 21
+		"Other category",          // 22
+		"==============",          // 23
+		"",                        // 24
+		"###### Deep card ######", // 25
+		"back",                    // 26
+	}, "\n")
+	lines, _ := splitLines(md)
+	sections, warnings := parseMarkdown("deck.md", lines, 0)
+	want := []section{
+		{level: 1, line: 0, start: 1, end: 2},
+		{level: 2, line: 2, start: 3, end: 9},
+		{level: 2, line: 9, start: 11, end: 22},
+		{level: 1, line: 22, start: 24, end: 25},
+		{level: 6, line: 25, start: 26, end: 27},
+	}
+	if !slices.Equal(sections, want) {
+		t.Errorf("sections = %+v, want %+v", sections, want)
+	}
+	if len(warnings) != 1 || warnings[0].Rule != "html-heading" || warnings[0].Line != 18 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+	if got := extractQuestion(lines[25]); got != "Deep card" {
+		t.Errorf("question of a closed heading = %q, want %q", got, "Deep card")
+	}
+}
+
+func TestParseMarkdownWarnings(t *testing.T) {
+	tests := []struct {
+		md       string
+		wantRule string
+		wantLine int
+	}{
+		{"# C\n\n## Q\n\n```\ncode", "unclosed-fence", 5},
+		{"# C\n\n## Q\n\n<pre>\ncode", "unclosed-html", 5},
+		{"# C\n\nfirst line\nsecond line\n---", "setext-heading", 5},
+		{"# C\n\n## Q\n\nA <!--mdfc:2;abcd1234;0;2023-03-01-->", "orphaned-metadata", 5},
+	}
+	for _, tt := range tests {
+		lines, _ := splitLines(tt.md)
+		_, warnings := parseMarkdown("deck.md", lines, 0)
+		if len(warnings) != 1 || warnings[0].Rule != tt.wantRule || warnings[0].Line != tt.wantLine {
+			t.Errorf("parseMarkdown(%q) warnings = %v, want %s in line %d", tt.md, warnings, tt.wantRule, tt.wantLine)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, data := range []string{"", "a", "a\n", "a\r\nb\n\nc", "a\r\n\r\n", "\n\n", "a\rb\n"} {
+		lines, endings := splitLines(data)
+		if len(lines) != len(endings) {
+			t.Fatalf("splitLines(%q) returns %d lines and %d endings", data, len(lines), len(endings))
+		}
+		for _, line := range lines {
+			if strings.Contains(line, "\n") || strings.HasSuffix(line, "\r") {
+				t.Errorf("splitLines(%q) returns the line %q with its ending", data, line)
+			}
+		}
+		if got := joinLines(lines, endings); got != data {
+			t.Errorf("joinLines(splitLines(%q)) = %q", data, got)
+		}
+	}
+}
+
+func TestReadFileKeepsBytes(t *testing.T) {
+	// Everything but the headings of new cards is written back as it is: line endings, trailing whitespace, the
+	// front matter, headings in code, and the missing newline at the end of the file.
+	md := "---\r\nscheduler: sm2\r\n---\r\n# Category  \r\n\r\n## New card\r\n\r\n```\r\n## code\r\n```\r\n" +
+		"\tindented\r\n\r\n## Saved <!--mdfc:2;abcd1234;1;2023-03-01;ease=2.50;ivl=1-->\r\n\r\nback \t"
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, md)
+	file, err := readFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Cards) != 2 {
+		t.Fatalf("got %d cards, want 2", len(file.Cards))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLines, gotEndings := splitLines(string(data))
+	wantLines, wantEndings := splitLines(md)
+	if !slices.Equal(gotEndings, wantEndings) || len(gotLines) != len(wantLines) {
+		t.Fatalf("file changed to %q", data)
+	}
+	for i := range wantLines {
+		if i == 5 {
+			want := "## New card " + formatMetadata(&file.Cards[0])
+			if gotLines[i] != want {
+				t.Errorf("heading of the new card = %q, want %q", gotLines[i], want)
+			}
+		} else if gotLines[i] != wantLines[i] {
+			t.Errorf("line %d changed from %q to %q", i+1, wantLines[i], gotLines[i])
+		}
+	}
+
+	// Reading the file again doesn't change it.
+	if _, err = readFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != string(data) {
+		t.Errorf("file changed again to %q", again)
+	}
+}
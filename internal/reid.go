@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// collision is a card whose ID is already used by a card of another file.
+type collision struct {
+	// Index of the file of the card in the list of files.
+	file int
+	card *Card
+	// Path and line index of the heading of the first card with the ID.
+	firstPath string
+	firstLine int
+}
+
+// findCollisions Returns the cards whose ID is already used by a card of an earlier file. Duplicates within a file
+// are replaced by readFile and are not reported.
+func findCollisions(files []File) []collision {
+	type place struct {
+		path string
+		line int
+	}
+	first := make(map[string]place)
+	var collisions []collision
+	for i := range files {
+		for j := range files[i].Cards {
+			c := &files[i].Cards[j]
+			p, ok := first[c.Id]
+			if !ok {
+				first[c.Id] = place{path: files[i].Path, line: c.heading}
+			} else if p.path != files[i].Path {
+				collisions = append(collisions, collision{file: i, card: c, firstPath: p.path, firstLine: p.line})
+			}
+		}
+	}
+	return collisions
+}
+
+// warning Returns the warning about the collision.
+func (c collision) warning(path string) Warning {
+	return Warning{Path: path, Line: c.card.heading + 1, Rule: "colliding-id", Message: fmt.Sprintf(
+		"card ID %s is also used in %s:%d, run 'mdfc reid' to give the card a new ID", c.card.Id, c.firstPath,
+		c.firstLine+1)}
+}
+
+// checkIds Adds a warning to the files for each card whose ID is already used in another file of the session.
+func (s *Session) checkIds() {
+	for _, c := range findCollisions(s.Files) {
+		f := &s.Files[c.file]
+		f.Warnings = append(f.Warnings, c.warning(f.Path))
+	}
+}
+
+// CheckIds Checks that the card IDs are unique across the given files and returns an issue for each card whose ID is
+// already used by a card of an earlier file. The issues can't be fixed by Lint, see Reid.
+func CheckIds(paths []string) ([]Issue, error) {
+	files := make([]File, 0, len(paths))
+	for _, path := range paths {
+		file, err := readFile(path, false)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	var issues []Issue
+	for _, c := range findCollisions(files) {
+		issues = append(issues, Issue{Warning: c.warning(files[c.file].Path)})
+	}
+	return issues, nil
+}
+
+// Rekey is a card that got a new ID from Reid.
+type Rekey struct {
+	Path string `json:"path"`
+	// Line number of the card's heading, starting at 1.
+	Line  int    `json:"line"`
+	OldId string `json:"oldId"`
+	NewId string `json:"newId"`
+	// Number of reviews of the card in the review history that were moved to the new ID.
+	Reviews int `json:"reviews"`
+}
+
+func (r Rekey) String() string {
+	return fmt.Sprintf("%s:%d: %s -> %s (%d reviews)", r.Path, r.Line, r.OldId, r.NewId, r.Reviews)
+}
+
+// Reid Gives a new ID to each card whose ID is already used by a card of an earlier file, so that the IDs are unique
+// across all given files. The first card with an ID keeps it. The reviews of a re-keyed card in the review history of
+// its file are moved to the new ID, since they belong to this card. If dryRun is true, the files and histories are
+// not changed.
+func Reid(paths []string, dryRun bool) ([]Rekey, error) {
+	if !dryRun {
+		for _, path := range paths {
+			lock, err := lockFile(path)
+			if err != nil {
+				return nil, err
+			}
+			defer lock.unlock()
+		}
+	}
+	files := make([]File, 0, len(paths))
+	ids := make(map[string]bool)
+	for _, path := range paths {
+		// Writing the file first replaces duplicate IDs within the file and adds missing metadata.
+		file, err := readFile(path, !dryRun)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range file.Cards {
+			ids[c.Id] = true
+		}
+		files = append(files, file)
+	}
+
+	var rekeys []Rekey
+	for _, c := range findCollisions(files) {
+		f := &files[c.file]
+		id := newId(f.IdLength)
+		for ids[id] {
+			id = newId(f.IdLength)
+		}
+		ids[id] = true
+		rekeys = append(rekeys, Rekey{Path: f.Path, Line: c.card.heading + 1, OldId: c.card.Id, NewId: id})
+	}
+
+	for i := range files {
+		var fileRekeys []*Rekey
+		for j := range rekeys {
+			if rekeys[j].Path == files[i].Path {
+				fileRekeys = append(fileRekeys, &rekeys[j])
+			}
+		}
+		if len(fileRekeys) == 0 {
+			continue
+		}
+		if err := reidFile(&files[i], fileRekeys, dryRun); err != nil {
+			return nil, err
+		}
+	}
+	return rekeys, nil
+}
+
+// reidFile Replaces the IDs of the cards of the file and moves their reviews in the review history to the new IDs.
+// The number of moved reviews is stored in the Rekeys.
+func reidFile(f *File, rekeys []*Rekey, dryRun bool) error {
+	newIds := make(map[string]string)
+	lines, endings := splitLines(f.content)
+	for _, r := range rekeys {
+		newIds[r.OldId] = r.NewId
+		re := regexp.MustCompile(metadataPattern(regexp.QuoteMeta(r.OldId)))
+		lines[r.Line-1] = replaceId(re, lines[r.Line-1], r.NewId)
+	}
+
+	history, counts, err := reidHistory(f.Path, newIds)
+	if err != nil {
+		return err
+	}
+	for _, r := range rekeys {
+		r.Reviews = counts[r.OldId]
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err = writeFileAtomic(f.Path, []byte(joinLines(lines, endings))); err != nil {
+		return &IOError{Op: "write", Path: f.Path, Err: err}
+	}
+	if history != nil {
+		if err = writeFileAtomic(HistoryPath(f.Path), history); err != nil {
+			return &IOError{Op: "write", Path: HistoryPath(f.Path), Err: err}
+		}
+	}
+	return nil
+}
+
+// reidHistory Returns the review history of the deck file with the IDs replaced by the new ones and the number of
+// replaced reviews per old ID. Lines of other cards are kept as they are. The history is nil if the deck has none.
+func reidHistory(path string, newIds map[string]string) (history []byte, counts map[string]int, err error) {
+	counts = make(map[string]int)
+	data, err := os.ReadFile(HistoryPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, counts, nil
+	}
+	if err != nil {
+		return nil, nil, &IOError{Op: "read", Path: HistoryPath(path), Err: err}
+	}
+
+	var b bytes.Buffer
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if i == len(lines)-1 && len(line) == 0 {
+			break
+		}
+		var r Review
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err = json.Unmarshal(line, &r); err != nil && i == len(lines)-1 {
+				// An incomplete last line, which ReadHistory ignores, is kept as it is.
+				b.Write(line)
+				break
+			} else if err != nil {
+				return nil, nil, &ParseError{Path: HistoryPath(path), Line: i + 1,
+					Message: fmt.Sprintf("invalid review: %v", err)}
+			}
+		}
+		if id, ok := newIds[r.Id]; ok {
+			counts[r.Id]++
+			r.Id = id
+			if line, err = json.Marshal(r); err != nil {
+				return nil, nil, err
+			}
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), counts, nil
+}
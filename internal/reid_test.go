@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReid(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")
+	deckA := "# A\n\n## Q1 <!--mdfc:2;abcd1234;2;2023-03-01-->\n\nA1\n"
+	deckB := "# B\n\n## Q2 <!--mdfc:2;efgh5678;1;2023-03-01-->\n\nA2\n\n" +
+		"## Q3 <!--mdfc:2;abcd1234;3;2023-03-02-->\n\nA3\n"
+	review := func(id, grade string, box int) string {
+		return fmt.Sprintf(`{"id":%q,"time":"2023-02-28T10:00:00Z","grade":%q,"prevBox":1,"box":%d,`+
+			`"due":"2023-03-01","durationMs":1000}`+"\n", id, grade, box)
+	}
+	historyA := review("abcd1234", GradeOkay, 2)
+	historyB := review("abcd1234", GradeOkay, 2) + review("efgh5678", GradeHard, 1) + review("abcd1234", GradeEasy, 3)
+	writeTestFile(t, a, deckA)
+	writeTestFile(t, b, deckB)
+	writeTestFile(t, HistoryPath(a), historyA)
+	writeTestFile(t, HistoryPath(b), historyB)
+	readTest := func(path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// A dry run reports the new ID without changing anything.
+	rekeys, err := Reid([]string{a, b}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rekeys) != 1 || rekeys[0].Path != b || rekeys[0].Line != 7 || rekeys[0].OldId != "abcd1234" ||
+		rekeys[0].Reviews != 2 {
+		t.Fatalf("dry run = %v", rekeys)
+	}
+	if readTest(b) != deckB || readTest(HistoryPath(b)) != historyB {
+		t.Error("dry run changed the files")
+	}
+
+	rekeys, err = Reid([]string{a, b}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rekeys) != 1 || rekeys[0].Path != b || rekeys[0].OldId != "abcd1234" || rekeys[0].Reviews != 2 {
+		t.Fatalf("Reid() = %v", rekeys)
+	}
+	newId := rekeys[0].NewId
+	if !idRegex.MatchString(newId) || newId == "abcd1234" || newId == "efgh5678" {
+		t.Fatalf("invalid new ID %q", newId)
+	}
+
+	// The first card keeps its ID and its history.
+	if readTest(a) != deckA || readTest(HistoryPath(a)) != historyA {
+		t.Error("the file of the first card changed")
+	}
+	if got, want := readTest(b), strings.Replace(deckB, "abcd1234", newId, 1); got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	history, err := ReadHistory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantIds := []string{newId, "efgh5678", newId}
+	if len(history) != len(wantIds) {
+		t.Fatalf("got %d reviews, want %d", len(history), len(wantIds))
+	}
+	for i, r := range history {
+		if r.Id != wantIds[i] {
+			t.Errorf("review %d of card %s, want %s", i, r.Id, wantIds[i])
+		}
+	}
+	if history[2].Grade != GradeEasy || history[2].Box != 3 || history[2].DurationMs != 1000 {
+		t.Errorf("moved review changed to %+v", history[2])
+	}
+
+	// The IDs are unique now.
+	if issues, err := CheckIds([]string{a, b}); err != nil || len(issues) != 0 {
+		t.Errorf("CheckIds() = %v, %v", issues, err)
+	}
+	if rekeys, err = Reid([]string{a, b}, false); err != nil || len(rekeys) != 0 {
+		t.Errorf("second Reid() = %v, %v", rekeys, err)
+	}
+}
+
+func TestReidHistoryIncompleteLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deck.md")
+	complete := `{"id":"abcd","time":"2023-02-28T10:00:00Z","grade":"okay","prevBox":0,"box":1,"due":"2023-03-01",` +
+		`"durationMs":0}` + "\n"
+	writeTestFile(t, HistoryPath(path), complete+`{"id":"abcd","ti`)
+	history, counts, err := reidHistory(path, map[string]string{"abcd": "wxyz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(complete, "abcd", "wxyz", 1) + `{"id":"abcd","ti`
+	if string(history) != want || counts["abcd"] != 1 {
+		t.Errorf("reidHistory() = %q, %v, want %q", history, counts, want)
+	}
+}
@@ -0,0 +1,560 @@
+package internal
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape codes used by the renderer.
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "1"
+	ansiDim       = "2"
+	ansiItalic    = "3"
+	ansiUnderline = "4"
+	ansiStrike    = "9"
+	ansiRed       = "31"
+	ansiGreen     = "32"
+	ansiYellow    = "33"
+	ansiMagenta   = "35"
+	ansiCyan      = "36"
+	ansiGray      = "90"
+)
+
+// UseColor Returns true if the output may be styled with ANSI escape codes, i.e. if standard output is a terminal and
+// the NO_COLOR environment variable is not set (see https://no-color.org).
+func UseColor() bool {
+	return os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+var (
+	sgrRegex          = regexp.MustCompile("\033\\[[0-9;]*m")
+	fenceRegex        = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	listItemRegex     = regexp.MustCompile(`^(\s*)([-+*]|\d+[.)])\s+(.*)$`)
+	taskRegex         = regexp.MustCompile(`^\[([ xX])\]\s+`)
+	headingRegex      = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	quoteRegex        = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	ruleRegex         = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	tableSepRegex     = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	codeSpanRegex     = regexp.MustCompile("(`+)(.+?)(`+)")
+	linkRegex         = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	autolinkRegex     = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	boldRegex         = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	italicRegex       = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*|(?:^|\b)_(\S(?:[^_]*?\S)?)_(?:\b|$)`)
+	strikeRegex       = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	htmlCommentRegex  = regexp.MustCompile(`<!--.*?-->`)
+	escapedCharRegex  = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|~<>])`)
+	escapePlaceholder = "\x00"
+)
+
+// renderer renders markdown for the terminal.
+type renderer struct {
+	width int
+	color bool
+}
+
+// newRenderer Returns a renderer that wraps lines to lineLength, or to the terminal width if it is 0.
+func newRenderer(lineLength uint, color bool) renderer {
+	if lineLength == 0 {
+		width, _, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil || width <= 0 {
+			width = 80
+		}
+		lineLength = uint(width)
+	}
+	return renderer{width: int(lineLength), color: color}
+}
+
+// RenderMarkdown Renders markdown for the terminal. Emphasis, inline code and links are styled with ANSI escape codes
+// if color is true; otherwise their markup is removed. Lines are wrapped to lineLength with hanging indents for list
+// items and block quotes. Fenced code blocks are highlighted but keep their indentation and are never wrapped, and
+// tables are aligned.
+//
+// If the lineLength is 0, it will wrap the text depending on the terminal width.
+func RenderMarkdown(md string, lineLength uint, color bool) string {
+	r := newRenderer(lineLength, color)
+
+	var out []string
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Fenced code block
+		if m := fenceRegex.FindStringSubmatch(line); m != nil {
+			fence := m[1]
+			language := strings.ToLower(m[2])
+			if r.color && language != "" {
+				out = append(out, "    "+r.style(ansiGray, language))
+			}
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+				out = append(out, "    "+r.highlight(lines[i], language))
+			}
+			continue
+		}
+
+		// Table: a header row followed by a separator row.
+		if strings.Contains(line, "|") && i+1 < len(lines) && tableSepRegex.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-") {
+			rows := [][]string{splitTableRow(line)}
+			alignments := tableAlignments(lines[i+1])
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				rows = append(rows, splitTableRow(lines[i]))
+			}
+			i--
+			out = append(out, r.table(rows, alignments)...)
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			out = append(out, "")
+		case ruleRegex.MatchString(line):
+			if r.color {
+				out = append(out, r.style(ansiGray, strings.Repeat("─", r.width/2)))
+			} else {
+				out = append(out, strings.TrimSpace(line))
+			}
+		case headingRegex.MatchString(line):
+			m := headingRegex.FindStringSubmatch(line)
+			text := r.inline(m[2])
+			if r.color {
+				text = r.style(ansiBold+";"+ansiUnderline, text)
+			}
+			out = append(out, r.wrap(text, "", "")...)
+		case quoteRegex.MatchString(line):
+			m := quoteRegex.FindStringSubmatch(line)
+			prefix := "> "
+			if r.color {
+				prefix = r.style(ansiGray, "│ ")
+			}
+			out = append(out, r.wrap(r.inline(m[1]), prefix, prefix)...)
+		case listItemRegex.MatchString(line):
+			m := listItemRegex.FindStringSubmatch(line)
+			indent, marker, text := m[1], m[2], m[3]
+			if r.color && !unicode.IsDigit(rune(marker[0])) {
+				marker = "•"
+			}
+			if t := taskRegex.FindStringSubmatch(text); t != nil {
+				checkbox := "[" + t[1] + "] "
+				if r.color {
+					checkbox = "☐ "
+					if t[1] != " " {
+						checkbox = "☑ "
+					}
+				}
+				text = checkbox + text[len(t[0]):]
+			}
+			first := indent + marker + " "
+			rest := strings.Repeat(" ", len([]rune(first)))
+			out = append(out, r.wrap(r.inline(text), first, rest)...)
+		default:
+			// Keep the indentation of the line, e.g. for the continuation of a list item.
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			out = append(out, r.wrap(r.inline(strings.TrimSpace(line)), indent, indent)...)
+		}
+	}
+
+	return strings.Join(out, "\n") + "\n"
+}
+
+// style Wraps the text in the given SGR code. Resets inside the text, e.g. of nested styles, restore the style.
+func (r renderer) style(code, text string) string {
+	if !r.color || text == "" {
+		return text
+	}
+	start := "\033[" + code + "m"
+	return start + strings.ReplaceAll(text, ansiReset, ansiReset+start) + ansiReset
+}
+
+// inline Renders the inline markdown of a line: code spans, links, emphasis and strikethrough. Without color, the
+// markup is removed.
+func (r renderer) inline(text string) string {
+	return renderInline(htmlCommentRegex.ReplaceAllString(text, ""), ansiInline{r})
+}
+
+// emphasis is the kind of an emphasized span of inline markdown.
+type emphasis int
+
+const (
+	emphasisBold emphasis = iota
+	emphasisItalic
+	emphasisStrike
+)
+
+// inlineFormat formats the spans of inline markdown for an output, see renderInline. The text, labels and URLs are
+// passed as they are written in the markdown; the content of an emphasis is already formatted.
+type inlineFormat interface {
+	text(s string) string
+	code(s string) string
+	link(label, url string) string
+	image(alt, url string) string
+	emphasis(e emphasis, content string) string
+}
+
+// ansiInline formats inline markdown with the escape codes of the renderer.
+type ansiInline struct {
+	r renderer
+}
+
+func (f ansiInline) text(s string) string {
+	return s
+}
+
+func (f ansiInline) code(s string) string {
+	return f.r.style(ansiCyan, s)
+}
+
+func (f ansiInline) link(label, url string) string {
+	if label == "" || label == url {
+		return f.r.style(ansiUnderline, url)
+	}
+	return f.r.style(ansiUnderline, label) + " " + f.r.style(ansiGray, "("+url+")")
+}
+
+func (f ansiInline) image(alt, url string) string {
+	return f.r.style(ansiGray, "[image: "+alt+"]")
+}
+
+func (f ansiInline) emphasis(e emphasis, content string) string {
+	return f.r.style([]string{ansiBold, ansiItalic, ansiStrike}[e], content)
+}
+
+// renderInline Renders the inline markdown of a line with the given format. Code spans are rendered first, their
+// content is not interpreted.
+func renderInline(text string, f inlineFormat) string {
+	// Backslash escaped characters must not be interpreted as markup.
+	var escaped []string
+	text = escapedCharRegex.ReplaceAllStringFunc(text, func(s string) string {
+		escaped = append(escaped, s[1:])
+		return escapePlaceholder
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, m := range codeSpanRegex.FindAllStringSubmatchIndex(text, -1) {
+		if text[m[2]:m[3]] != text[m[6]:m[7]] {
+			continue
+		}
+		b.WriteString(renderSpans(text[last:m[0]], f))
+		b.WriteString(f.code(strings.TrimSpace(text[m[4]:m[5]])))
+		last = m[1]
+	}
+	b.WriteString(renderSpans(text[last:], f))
+
+	result := b.String()
+	for _, e := range escaped {
+		result = strings.Replace(result, escapePlaceholder, f.text(e), 1)
+	}
+	return result
+}
+
+// spanPatterns are the patterns of the spans of inline markdown. If two spans start at the same position, the first
+// pattern wins.
+var spanPatterns = []*regexp.Regexp{linkRegex, autolinkRegex, boldRegex, italicRegex, strikeRegex}
+
+// renderSpans Renders the links and emphasis of text that doesn't contain code spans. The text is split at the
+// leftmost span, whose offsets are taken from the submatches of its pattern; the content of an emphasis is rendered
+// recursively.
+func renderSpans(text string, f inlineFormat) string {
+	matches := make([][][]int, len(spanPatterns))
+	for i, re := range spanPatterns {
+		matches[i] = re.FindAllStringSubmatchIndex(text, -1)
+	}
+	var b strings.Builder
+	pos := 0
+	for {
+		pattern, span := -1, []int(nil)
+		for i := range spanPatterns {
+			for _, m := range matches[i] {
+				if m[0] < pos || isDelimiterRun(text, m) {
+					continue
+				}
+				if span == nil || m[0] < span[0] {
+					pattern, span = i, m
+				}
+				break
+			}
+		}
+		if span == nil {
+			break
+		}
+		b.WriteString(f.text(text[pos:span[0]]))
+		group := func(n int) string {
+			if span[2*n] < 0 {
+				return ""
+			}
+			return text[span[2*n]:span[2*n+1]]
+		}
+		switch spanPatterns[pattern] {
+		case linkRegex:
+			if group(1) == "!" {
+				b.WriteString(f.image(group(2), group(3)))
+			} else {
+				b.WriteString(f.link(group(2), group(3)))
+			}
+		case autolinkRegex:
+			b.WriteString(f.link(group(1), group(1)))
+		case boldRegex:
+			b.WriteString(f.emphasis(emphasisBold, renderSpans(group(1)+group(2), f)))
+		case italicRegex:
+			b.WriteString(f.emphasis(emphasisItalic, renderSpans(group(1)+group(2), f)))
+		case strikeRegex:
+			b.WriteString(f.emphasis(emphasisStrike, renderSpans(group(1), f)))
+		}
+		pos = span[1]
+	}
+	b.WriteString(f.text(text[pos:]))
+	return b.String()
+}
+
+// isDelimiterRun Returns true if the match of an emphasis only consists of its delimiter characters, e.g. the blank
+// `___` of a fill-in-the-blank sentence, which is not emphasis.
+func isDelimiterRun(text string, m []int) bool {
+	return strings.Trim(text[m[0]:m[1]], "*_~") == ""
+}
+
+// visibleLen Returns the number of characters of the text without escape codes.
+func visibleLen(text string) int {
+	return len([]rune(sgrRegex.ReplaceAllString(text, "")))
+}
+
+// wrap Wraps the styled text at whitespace. The first line starts with firstPrefix and all following lines with
+// restPrefix. Styles that span a line break are closed at the end of the line and restored on the next line.
+func (r renderer) wrap(text, firstPrefix, restPrefix string) []string {
+	var lines []string
+	line := firstPrefix
+	lineLen := visibleLen(firstPrefix)
+	empty := true
+	active := ""
+	for _, word := range strings.Fields(text) {
+		wordLen := visibleLen(word)
+		if !empty && lineLen+1+wordLen > r.width {
+			if active != "" {
+				line += ansiReset
+			}
+			lines = append(lines, line)
+			line = restPrefix + active
+			lineLen = visibleLen(restPrefix)
+			empty = true
+		}
+		if !empty {
+			line += " "
+			lineLen++
+		}
+		line += word
+		lineLen += wordLen
+		empty = false
+		for _, sgr := range sgrRegex.FindAllString(word, -1) {
+			if sgr == ansiReset {
+				active = ""
+			} else {
+				active += sgr
+			}
+		}
+	}
+	return append(lines, line)
+}
+
+// splitTableRow Splits a table row into its cells.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// tableAlignments Returns the alignment of each column ('l', 'c' or 'r') according to the separator row.
+func tableAlignments(separator string) []byte {
+	var alignments []byte
+	for _, cell := range splitTableRow(separator) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			alignments = append(alignments, 'c')
+		case strings.HasSuffix(cell, ":"):
+			alignments = append(alignments, 'r')
+		default:
+			alignments = append(alignments, 'l')
+		}
+	}
+	return alignments
+}
+
+// table Renders a table with aligned columns. The first row is the header.
+func (r renderer) table(rows [][]string, alignments []byte) []string {
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	widths := make([]int, columns)
+	rendered := make([][]string, len(rows))
+	for i, row := range rows {
+		rendered[i] = make([]string, columns)
+		for j := range rendered[i] {
+			if j < len(row) {
+				rendered[i][j] = r.inline(row[j])
+			}
+			if l := visibleLen(rendered[i][j]); l > widths[j] {
+				widths[j] = l
+			}
+		}
+	}
+
+	separator := " | "
+	if r.color {
+		separator = r.style(ansiGray, " │ ")
+	}
+	var out []string
+	for i, row := range rendered {
+		cells := make([]string, columns)
+		for j, cell := range row {
+			padding := widths[j] - visibleLen(cell)
+			alignment := byte('l')
+			if j < len(alignments) {
+				alignment = alignments[j]
+			}
+			if i == 0 {
+				cell = r.style(ansiBold, cell)
+			}
+			switch alignment {
+			case 'r':
+				cell = strings.Repeat(" ", padding) + cell
+			case 'c':
+				cell = strings.Repeat(" ", padding/2) + cell + strings.Repeat(" ", padding-padding/2)
+			default:
+				cell += strings.Repeat(" ", padding)
+			}
+			cells[j] = cell
+		}
+		out = append(out, strings.TrimRight(strings.Join(cells, separator), " "))
+		if i == 0 {
+			// Separator between the header and the body.
+			parts := make([]string, columns)
+			for j, w := range widths {
+				parts[j] = strings.Repeat("-", w)
+				if r.color {
+					parts[j] = strings.Repeat("─", w)
+				}
+			}
+			if r.color {
+				out = append(out, r.style(ansiGray, strings.Join(parts, "─┼─")))
+			} else {
+				out = append(out, strings.Join(parts, "-+-"))
+			}
+		}
+	}
+	return out
+}
+
+// Keywords of common programming languages that are highlighted in code blocks.
+var codeKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`and as async await break case catch class const continue def default defer del
+		do elif else enum except export extends false False final finally fn for foreach from func function go goto if
+		impl import in interface is lambda let loop match mod module mut new nil None not null or package pass private
+		protected pub public raise return select self static struct super switch this throw throws true True try type
+		typeof use var void where while with yield int float double bool boolean string char byte long short uint
+		SELECT FROM WHERE INSERT INTO UPDATE DELETE CREATE TABLE JOIN ON GROUP BY ORDER LIMIT AND OR NOT NULL VALUES`) {
+		codeKeywords[k] = true
+	}
+}
+
+// commentPrefixes Returns the tokens that start a line comment in the given language.
+func commentPrefixes(language string) []string {
+	switch language {
+	case "python", "py", "sh", "bash", "zsh", "shell", "ruby", "rb", "yaml", "yml", "toml", "r", "perl",
+		"dockerfile", "make", "makefile", "ini", "conf":
+		return []string{"#"}
+	case "sql", "lua", "haskell", "hs":
+		return []string{"--"}
+	case "lisp", "clojure", "scheme", "asm":
+		return []string{";"}
+	}
+	return []string{"//"}
+}
+
+// highlight Applies a basic syntax highlighting to a line of code: comments, strings, numbers, and keywords.
+func (r renderer) highlight(line, language string) string {
+	if !r.color {
+		return line
+	}
+	comments := commentPrefixes(language)
+	var b strings.Builder
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		rest := string(runes[i:])
+		isComment := false
+		for _, c := range comments {
+			if strings.HasPrefix(rest, c) {
+				isComment = true
+			}
+		}
+		ch := runes[i]
+		switch {
+		case isComment:
+			b.WriteString(r.style(ansiGray, rest))
+			i = len(runes)
+		case ch == '"' || ch == '\'' || ch == '`':
+			j := i + 1
+			for j < len(runes) && runes[j] != ch {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(runes) {
+				j++
+			} else {
+				j = len(runes)
+			}
+			b.WriteString(r.style(ansiGreen, string(runes[i:j])))
+			i = j
+		case unicode.IsDigit(ch):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == '_' ||
+				unicode.IsLetter(runes[j])) {
+				j++
+			}
+			b.WriteString(r.style(ansiYellow, string(runes[i:j])))
+			i = j
+		case unicode.IsLetter(ch) || ch == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			word := string(runes[i:j])
+			if codeKeywords[word] {
+				word = r.style(ansiMagenta, word)
+			}
+			b.WriteString(word)
+			i = j
+		default:
+			b.WriteRune(ch)
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,32 @@
+package internal
+
+import "testing"
+
+func TestInline(t *testing.T) {
+	tests := []struct {
+		name, text string
+		plain      string
+		color      string
+	}{
+		{"blank", "The ___ is the powerhouse", "The ___ is the powerhouse", "The ___ is the powerhouse"},
+		{"repeated underscores", "_a_ _a_", "a a", "\033[3ma\033[0m \033[3ma\033[0m"},
+		{"repeated stars", "*x* x", "x x", "\033[3mx\033[0m x"},
+		{"nested", "**bold _it_**", "bold it", "\033[1mbold \033[3mit\033[0m\033[1m\033[0m"},
+		{"code", "`*x*` *y*", "*x* y", "\033[36m*x*\033[0m \033[3my\033[0m"},
+		{"escaped", `\*x\* ~~y~~`, "*x* y", "*x* \033[9my\033[0m"},
+		{"link", "[mdfc](https://example.com)", "mdfc (https://example.com)",
+			"\033[4mmdfc\033[0m \033[90m(https://example.com)\033[0m"},
+		{"autolink", "<https://example.com>", "https://example.com", "\033[4mhttps://example.com\033[0m"},
+		{"snake case", "snake_case_name", "snake_case_name", "snake_case_name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (renderer{}).inline(tt.text); got != tt.plain {
+				t.Errorf("inline(%q) = %q, want %q", tt.text, got, tt.plain)
+			}
+			if got := (renderer{color: true}).inline(tt.text); got != tt.color {
+				t.Errorf("inline(%q) with color = %q, want %q", tt.text, got, tt.color)
+			}
+		})
+	}
+}
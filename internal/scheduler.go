@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"math"
+	"time"
+)
+
+// Scheduler decides when a card is due again after it has been reviewed.
+type Scheduler interface {
+	// Schedule updates the card's box, scheduling state and due date according to how difficult it was to remember
+	// the card. If the card was not remembered, it must be due today.
+	Schedule(c *Card, difficulty float32, today time.Time)
+}
+
+// Names of the available schedulers as they are specified in the front matter.
+const (
+	SchedulerLeitner = "leitner"
+	SchedulerSM2     = "sm2"
+	SchedulerFSRS    = "fsrs"
+)
+
+// newScheduler Returns the scheduler with the given name for the file. An empty name selects the Leitner system.
+func newScheduler(name string, f *File) Scheduler {
+	switch name {
+	case SchedulerSM2:
+		return SM2{}
+	case SchedulerFSRS:
+		return FSRS{RequestRetention: f.RequestRetention}
+	default:
+		return Leitner{BoxIntervals: f.BoxIntervals, Multipliers: f.Multipliers}
+	}
+}
+
+// Leitner is the adapted Leitner system: A remembered card moves to the next box unless it was hard to remember, and
+// the interval of its box is scaled by the difficulty multiplier. A card that was not remembered moves back to the
+// first box.
+type Leitner struct {
+	BoxIntervals []uint
+	Multipliers  Multipliers
+}
+
+func (l Leitner) Schedule(c *Card, difficulty float32, today time.Time) {
+	if difficulty == NotRemembered {
+		c.Box = 0
+		c.Due = today
+		return
+	}
+	// Move the card to the next box but only if it is not in the last box and the answer was not Hard.
+	if difficulty != Hard && c.Box < uint(len(l.BoxIntervals))-1 {
+		c.Box++
+	}
+	daysInFuture := int(float32(l.BoxIntervals[c.Box]) * l.Multipliers.of(difficulty))
+	if daysInFuture == 0 {
+		// Since the int conversion floors the number, make sure the card is due at least one day in the future.
+		daysInFuture = 1
+	}
+	c.Due = today.AddDate(0, 0, daysInFuture)
+}
+
+// SM2 is the SuperMemo 2 algorithm. Each card has an ease factor that grows or shrinks with every review, and the
+// interval is multiplied by the ease factor. The box of a card is the number of successful reviews in a row.
+type SM2 struct{}
+
+const (
+	sm2InitialEase = 2.5
+	sm2MinEase     = 1.3
+)
+
+func (SM2) Schedule(c *Card, difficulty float32, today time.Time) {
+	// Map the difficulty to SM-2's quality of response (0-5).
+	var q float64
+	switch difficulty {
+	case NotRemembered:
+		q = 1
+	case Hard:
+		q = 3
+	case Okay:
+		q = 4
+	case Easy:
+		q = 5
+	}
+
+	if c.Ease == 0 {
+		c.Ease = sm2InitialEase
+	}
+	c.Ease = math.Max(sm2MinEase, c.Ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+
+	if q < 3 {
+		c.Box = 0
+		c.Interval = 0
+		c.Due = today
+		return
+	}
+	switch c.Box {
+	case 0:
+		c.Interval = 1
+	case 1:
+		c.Interval = 6
+	default:
+		c.Interval = uint(math.Round(float64(c.Interval) * c.Ease))
+	}
+	c.Box++
+	c.Due = today.AddDate(0, 0, int(c.Interval))
+}
+
+// FSRS is a scheduler based on the Free Spaced Repetition Scheduler (version 4.5). It models each card's memory by
+// its stability (the interval in days after which the probability of recalling it drops to 90%) and its difficulty
+// (1-10). The card is scheduled when the predicted probability of recalling it drops to RequestRetention. The box of a
+// card is the number of successful reviews since the last lapse.
+type FSRS struct {
+	// RequestRetention is the desired probability of recalling a card when it is due. Defaults to 0.9.
+	RequestRetention float64
+}
+
+// Default model weights of FSRS-4.5.
+var fsrsWeights = [17]float64{0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474, 0.1367, 1.0461,
+	2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755}
+
+const (
+	fsrsDecay            = -0.5
+	fsrsFactor           = 19.0 / 81
+	fsrsDefaultRetention = 0.9
+	fsrsMaxInterval      = 36500
+)
+
+// fsrsInitialDifficulty Returns the difficulty of a card after its first review with the given rating (1-4).
+func fsrsInitialDifficulty(rating float64) float64 {
+	w := fsrsWeights
+	return w[4] - math.Exp(w[5]*(rating-1)) + 1
+}
+
+func clampDifficulty(d float64) float64 {
+	return math.Min(10, math.Max(1, d))
+}
+
+func (f FSRS) Schedule(c *Card, difficulty float32, today time.Time) {
+	w := fsrsWeights
+	// Map the difficulty to FSRS' rating: again (1), hard (2), good (3), easy (4).
+	var rating float64
+	switch difficulty {
+	case NotRemembered:
+		rating = 1
+	case Hard:
+		rating = 2
+	case Okay:
+		rating = 3
+	case Easy:
+		rating = 4
+	}
+
+	if c.Stability == 0 {
+		// First review of the card.
+		c.Stability = w[int(rating)-1]
+		c.Difficulty = clampDifficulty(fsrsInitialDifficulty(rating))
+	} else {
+		elapsedDays := 0.0
+		if !c.LastReview.IsZero() {
+			elapsedDays = math.Max(0, today.Sub(c.LastReview).Hours()/24)
+		}
+		retrievability := math.Pow(1+fsrsFactor*elapsedDays/c.Stability, fsrsDecay)
+
+		d := c.Difficulty - w[6]*(rating-3)
+		// Mean reversion towards the difficulty of an easy card avoids that cards get stuck at a high difficulty.
+		c.Difficulty = clampDifficulty(w[7]*fsrsInitialDifficulty(4) + (1-w[7])*d)
+
+		if rating == 1 {
+			c.Stability = w[11] * math.Pow(c.Difficulty, -w[12]) * (math.Pow(c.Stability+1, w[13]) - 1) *
+				math.Exp(w[14]*(1-retrievability))
+		} else {
+			hardPenalty, easyBonus := 1.0, 1.0
+			if rating == 2 {
+				hardPenalty = w[15]
+			} else if rating == 4 {
+				easyBonus = w[16]
+			}
+			c.Stability *= 1 + math.Exp(w[8])*(11-c.Difficulty)*math.Pow(c.Stability, -w[9])*
+				(math.Exp(w[10]*(1-retrievability))-1)*hardPenalty*easyBonus
+		}
+	}
+	c.LastReview = today
+
+	if rating == 1 {
+		c.Box = 0
+		c.Interval = 0
+		c.Due = today
+		return
+	}
+	retention := f.RequestRetention
+	if retention <= 0 || retention >= 1 {
+		retention = fsrsDefaultRetention
+	}
+	interval := c.Stability / fsrsFactor * (math.Pow(retention, 1/fsrsDecay) - 1)
+	c.Interval = uint(math.Min(fsrsMaxInterval, math.Max(1, math.Round(interval))))
+	c.Box++
+	c.Due = today.AddDate(0, 0, int(c.Interval))
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// review is a review of a card on the given day after the first review, and the expected state of the card after it.
+type review struct {
+	day        int
+	difficulty float32
+	box        uint
+	// Number of days after the review on which the card is due.
+	due int
+}
+
+// testSchedule Reviews a new card with the scheduler and checks the card's box and due date after each review.
+func testSchedule(t *testing.T, s Scheduler, reviews []review, check func(i int, c *Card)) {
+	t.Helper()
+	start := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	c := &Card{Due: start}
+	for i, r := range reviews {
+		today := start.AddDate(0, 0, r.day)
+		s.Schedule(c, r.difficulty, today)
+		if c.Box != r.box || !c.Due.Equal(today.AddDate(0, 0, r.due)) {
+			t.Errorf("review %d: box %d due on %s, want box %d due on %s", i+1, c.Box, c.Due.Format("2006-01-02"),
+				r.box, today.AddDate(0, 0, r.due).Format("2006-01-02"))
+		}
+		if check != nil {
+			check(i, c)
+		}
+	}
+}
+
+func TestLeitner(t *testing.T) {
+	l := Leitner{BoxIntervals: boxIntervals, Multipliers: defaultMultipliers}
+	testSchedule(t, l, []review{
+		// A hard card stays in its box; the interval of at least one day is scaled by the multiplier.
+		{0, Hard, 0, 1},
+		{1, Okay, 1, 1},
+		{2, Easy, 2, 3},
+		{5, Hard, 2, 1},
+		{6, Okay, 3, 4},
+		{10, NotRemembered, 0, 0},
+	}, nil)
+
+	// A card stays in the last box.
+	c := &Card{Box: uint(len(boxIntervals)) - 1}
+	today := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	l.Schedule(c, Okay, today)
+	if c.Box != uint(len(boxIntervals))-1 || !c.Due.Equal(today.AddDate(0, 0, 25)) {
+		t.Errorf("card of the last box is moved to box %d due on %v", c.Box, c.Due)
+	}
+}
+
+func TestSM2(t *testing.T) {
+	eases := []float64{2.5, 2.5, 2.5, 2.36, 1.82, 1.82}
+	testSchedule(t, SM2{}, []review{
+		{0, Okay, 1, 1},
+		{1, Okay, 2, 6},
+		{7, Okay, 3, 15},
+		{22, Hard, 4, 35},
+		{57, NotRemembered, 0, 0},
+		{57, Okay, 1, 1},
+	}, func(i int, c *Card) {
+		if math.Abs(c.Ease-eases[i]) > 1e-9 {
+			t.Errorf("review %d: ease %v, want %v", i+1, c.Ease, eases[i])
+		}
+	})
+
+	// The ease doesn't drop below the minimum.
+	c := &Card{Ease: sm2MinEase}
+	SM2{}.Schedule(c, NotRemembered, time.Now())
+	if c.Ease != sm2MinEase {
+		t.Errorf("ease dropped to %v", c.Ease)
+	}
+}
+
+func TestFSRS(t *testing.T) {
+	type state struct {
+		stability, difficulty float64
+	}
+	states := []state{{3.7145, 1}, {22.7162, 1}, {4.2651, 1.6587}}
+	testSchedule(t, FSRS{}, []review{
+		// With the default retention, the interval is the stability.
+		{0, Okay, 1, 4},
+		{4, Okay, 2, 23},
+		{27, NotRemembered, 0, 0},
+	}, func(i int, c *Card) {
+		if math.Abs(c.Stability-states[i].stability) > 1e-4 || math.Abs(c.Difficulty-states[i].difficulty) > 1e-4 {
+			t.Errorf("review %d: stability %v and difficulty %v, want %v", i+1, c.Stability, c.Difficulty, states[i])
+		}
+		if c.Interval != uint(c.Due.Sub(c.LastReview).Hours()/24) {
+			t.Errorf("review %d: interval %d doesn't match the due date", i+1, c.Interval)
+		}
+	})
+
+	tests := []struct {
+		difficulty float32
+		retention  float64
+		// Stability and difficulty after the first review, and the interval in days.
+		stability, cardDifficulty float64
+		interval                  uint
+	}{
+		{NotRemembered, 0, 0.4872, 5.1618, 0},
+		{Easy, 0, 13.8206, 1, 14},
+		// A lower retention results in a longer interval.
+		{Okay, 0.8, 3.7145, 1, 9},
+	}
+	for _, tt := range tests {
+		c := &Card{}
+		FSRS{RequestRetention: tt.retention}.Schedule(c, tt.difficulty, time.Now())
+		if math.Abs(c.Stability-tt.stability) > 1e-4 || math.Abs(c.Difficulty-tt.cardDifficulty) > 1e-4 ||
+			c.Interval != tt.interval {
+			t.Errorf("first review %v with retention %v: stability %v, difficulty %v, interval %d, want %v, %v, %d",
+				tt.difficulty, tt.retention, c.Stability, c.Difficulty, c.Interval, tt.stability, tt.cardDifficulty,
+				tt.interval)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// sqliteDB is a read-only view of an SQLite database file in memory. It only supports what is needed to read the
+// collection of an Anki package: the rows of ordinary tables (table b-trees) in UTF-8 databases. Indexes and tables
+// WITHOUT ROWID are not supported.
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	// Usable size of a page, i.e. the page size without the reserved space at the end of each page.
+	usable int
+}
+
+// sqliteRow is a row of a table. The column of an INTEGER PRIMARY KEY is stored as NULL; its value is the rowid.
+type sqliteRow struct {
+	rowid  int64
+	values []any
+}
+
+// int Returns the column as an integer. NULL, missing columns and other types are 0.
+func (r sqliteRow) int(column int) int64 {
+	if column < len(r.values) {
+		switch v := r.values[column].(type) {
+		case int64:
+			return v
+		case float64:
+			return int64(v)
+		}
+	}
+	return 0
+}
+
+// text Returns the column as a string. NULL, missing columns and numbers are empty.
+func (r sqliteRow) text(column int) string {
+	if column < len(r.values) {
+		switch v := r.values[column].(type) {
+		case string:
+			return v
+		case []byte:
+			return string(v)
+		}
+	}
+	return ""
+}
+
+var errNotSQLite = errors.New("not an SQLite database")
+
+// openSQLite Checks the header of the database file and returns the database.
+func openSQLite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || !bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		return nil, errNotSQLite
+	}
+	db := &sqliteDB{data: data, pageSize: int(binary.BigEndian.Uint16(data[16:18]))}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	db.usable = db.pageSize - int(data[20])
+	if db.pageSize < 512 || db.usable < 480 {
+		return nil, errNotSQLite
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding != 0 && encoding != 1 {
+		return nil, errors.New("only SQLite databases in UTF-8 are supported")
+	}
+	return db, nil
+}
+
+// page Returns the page with the given number, starting at 1.
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	start := int64(n-1) * int64(db.pageSize)
+	if n == 0 || start+int64(db.pageSize) > int64(len(db.data)) {
+		return nil, fmt.Errorf("invalid page %d", n)
+	}
+	return db.data[start : start+int64(db.pageSize)], nil
+}
+
+// table Returns the rows of the table with the given name in the order of their rowid.
+func (db *sqliteDB) table(name string) ([]sqliteRow, error) {
+	schema, err := db.rows(1)
+	if err != nil {
+		return nil, err
+	}
+	// Columns of sqlite_schema: type, name, tbl_name, rootpage, sql
+	for _, r := range schema {
+		if r.text(0) == "table" && r.text(1) == name {
+			return db.rows(uint32(r.int(3)))
+		}
+	}
+	return nil, fmt.Errorf("table %s not found", name)
+}
+
+// hasTable Returns true if the database has a table with the given name.
+func (db *sqliteDB) hasTable(name string) bool {
+	schema, err := db.rows(1)
+	if err != nil {
+		return false
+	}
+	for _, r := range schema {
+		if r.text(0) == "table" && r.text(1) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rows Returns the rows of the table b-tree with the given root page.
+func (db *sqliteDB) rows(root uint32) ([]sqliteRow, error) {
+	var rows []sqliteRow
+	// Pages that were visited, so that a corrupt file can't cause an endless loop.
+	visited := make(map[uint32]bool)
+	var walk func(n uint32) error
+	walk = func(n uint32) error {
+		if visited[n] {
+			return fmt.Errorf("page %d is referenced twice", n)
+		}
+		visited[n] = true
+		p, err := db.page(n)
+		if err != nil {
+			return err
+		}
+		// The header of the first page follows the database header.
+		header := 0
+		if n == 1 {
+			header = 100
+		}
+		cells := int(binary.BigEndian.Uint16(p[header+3 : header+5]))
+		// The page header is followed by the cell pointer array, whose offsets point to the cells.
+		cellPointers := func(size int) ([]int, error) {
+			if header+size+2*cells > len(p) {
+				return nil, fmt.Errorf("invalid number of cells on page %d", n)
+			}
+			offsets := make([]int, cells)
+			for i := range offsets {
+				offsets[i] = int(binary.BigEndian.Uint16(p[header+size+2*i:]))
+				if offsets[i] < header+size+2*cells || offsets[i] >= len(p) {
+					return nil, fmt.Errorf("invalid cell offset on page %d", n)
+				}
+			}
+			return offsets, nil
+		}
+		switch p[header] {
+		case 0x05: // Interior page of a table b-tree
+			offsets, err := cellPointers(12)
+			if err != nil {
+				return err
+			}
+			for _, offset := range offsets {
+				if offset+4 > len(p) {
+					return fmt.Errorf("invalid cell on page %d", n)
+				}
+				if err = walk(binary.BigEndian.Uint32(p[offset:])); err != nil {
+					return err
+				}
+			}
+			return walk(binary.BigEndian.Uint32(p[header+8:]))
+		case 0x0d: // Leaf page of a table b-tree
+			offsets, err := cellPointers(8)
+			if err != nil {
+				return err
+			}
+			for _, offset := range offsets {
+				row, err := db.cell(p, offset)
+				if err != nil {
+					return fmt.Errorf("page %d: %w", n, err)
+				}
+				rows = append(rows, row)
+			}
+			return nil
+		}
+		return fmt.Errorf("page %d is not part of a table", n)
+	}
+	if err := walk(root); err != nil {
+		return nil, fmt.Errorf("invalid SQLite database: %w", err)
+	}
+	return rows, nil
+}
+
+// cell Reads the cell at the given offset of a leaf page: the size of the payload, the rowid and the record, which
+// may continue on overflow pages.
+func (db *sqliteDB) cell(p []byte, offset int) (sqliteRow, error) {
+	if offset >= len(p) {
+		return sqliteRow{}, errors.New("invalid cell offset")
+	}
+	size, n := readVarint(p[offset:])
+	offset += n
+	rowid, n := readVarint(p[offset:])
+	offset += n
+	if size > uint64(len(db.data)) {
+		return sqliteRow{}, errors.New("invalid cell size")
+	}
+
+	// See https://www.sqlite.org/fileformat.html#b_tree_pages for the amount of the payload stored on the page.
+	local := int(size)
+	maxLocal := db.usable - 35
+	if local > maxLocal {
+		minLocal := (db.usable-12)*32/255 - 23
+		local = minLocal + (int(size)-minLocal)%(db.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if offset+local > len(p) {
+		return sqliteRow{}, errors.New("invalid cell size")
+	}
+	payload := append([]byte(nil), p[offset:offset+local]...)
+	if local < int(size) {
+		if offset+local+4 > len(p) {
+			return sqliteRow{}, errors.New("invalid cell size")
+		}
+		next := binary.BigEndian.Uint32(p[offset+local:])
+		for len(payload) < int(size) {
+			overflow, err := db.page(next)
+			if err != nil {
+				return sqliteRow{}, err
+			}
+			rest := int(size) - len(payload)
+			if rest > db.usable-4 {
+				rest = db.usable - 4
+			}
+			payload = append(payload, overflow[4:4+rest]...)
+			next = binary.BigEndian.Uint32(overflow)
+		}
+	}
+
+	values, err := readRecord(payload)
+	return sqliteRow{rowid: int64(rowid), values: values}, err
+}
+
+// readRecord Decodes a record: a header with the serial type of each column followed by the values.
+func readRecord(payload []byte) ([]any, error) {
+	headerSize, n := readVarint(payload)
+	if headerSize > uint64(len(payload)) {
+		return nil, errors.New("invalid record header")
+	}
+	var values []any
+	body := payload[headerSize:]
+	for offset := n; offset < int(headerSize); {
+		serialType, n := readVarint(payload[offset:int(headerSize)])
+		offset += n
+		var size int
+		switch {
+		case serialType >= 12:
+			if serialType-12 > uint64(len(body))*2+1 {
+				return nil, errors.New("invalid record")
+			}
+			size = int(serialType-12) / 2
+		case serialType >= 1 && serialType <= 4:
+			size = int(serialType)
+		case serialType == 5:
+			size = 6
+		case serialType == 6 || serialType == 7:
+			size = 8
+		}
+		if size > len(body) {
+			return nil, errors.New("invalid record")
+		}
+		v := body[:size]
+		body = body[size:]
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType >= 1 && serialType <= 6:
+			// Big-endian two's complement integer
+			i := int64(int8(v[0]))
+			for _, b := range v[1:] {
+				i = i<<8 | int64(b)
+			}
+			values = append(values, i)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType >= 12 && serialType%2 == 0:
+			values = append(values, v)
+		case serialType >= 13:
+			values = append(values, string(v))
+		default:
+			return nil, fmt.Errorf("invalid serial type %d", serialType)
+		}
+	}
+	return values, nil
+}
+
+// readVarint Reads a variable-length integer of SQLite (1 to 9 bytes, big-endian) and returns it with its length.
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPageSize is the page size of the databases that newTestSQLite writes.
+const testPageSize = 4096
+
+// testTable is a table of a database that newTestSQLite writes. The rowid of a row is its index + 1.
+type testTable struct {
+	name string
+	rows [][]any
+}
+
+// testSQLite writes SQLite databases for tests: each table is a table b-tree of leaf pages, below an interior page if
+// it doesn't fit on one, and payloads that don't fit on a page continue on overflow pages.
+type testSQLite struct {
+	pages [][]byte
+}
+
+// newTestSQLite Returns an SQLite database with the given tables.
+func newTestSQLite(tables ...testTable) []byte {
+	db := &testSQLite{}
+	_, first := db.newPage()
+	var schema [][]byte
+	for i, table := range tables {
+		root := db.table(table.rows)
+		record := testRecord("table", table.name, table.name, int64(root), "CREATE TABLE "+table.name+" (...)")
+		schema = append(schema, db.leafCell(int64(i+1), record))
+	}
+	writeTestPage(first, 100, 0x0d, schema, 0)
+
+	copy(first, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(first[16:], testPageSize)
+	first[18], first[19], first[21], first[22], first[23] = 1, 1, 64, 32, 32
+	binary.BigEndian.PutUint32(first[28:], uint32(len(db.pages)))
+	binary.BigEndian.PutUint32(first[44:], 4)
+	binary.BigEndian.PutUint32(first[56:], 1)
+	var data []byte
+	for _, p := range db.pages {
+		data = append(data, p...)
+	}
+	return data
+}
+
+func (db *testSQLite) newPage() (uint32, []byte) {
+	p := make([]byte, testPageSize)
+	db.pages = append(db.pages, p)
+	return uint32(len(db.pages)), p
+}
+
+// table Writes the rows of a table and returns its root page.
+func (db *testSQLite) table(rows [][]any) uint32 {
+	type leaf struct {
+		page    uint32
+		lastRow int64
+	}
+	var leaves []leaf
+	var cells [][]byte
+	size := 8
+	flush := func(lastRow int64) {
+		n, p := db.newPage()
+		writeTestPage(p, 0, 0x0d, cells, 0)
+		leaves = append(leaves, leaf{n, lastRow})
+		cells, size = nil, 8
+	}
+	for i, values := range rows {
+		cell := db.leafCell(int64(i+1), testRecord(values...))
+		if size+2+len(cell) > testPageSize {
+			flush(int64(i))
+		}
+		cells = append(cells, cell)
+		size += 2 + len(cell)
+	}
+	flush(int64(len(rows)))
+	if len(leaves) == 1 {
+		return leaves[0].page
+	}
+	// The left child of each cell holds the rows up to its key; the right-most child holds the remaining rows.
+	cells = nil
+	for _, l := range leaves[:len(leaves)-1] {
+		cell := binary.BigEndian.AppendUint32(nil, l.page)
+		cells = append(cells, append(cell, testVarint(uint64(l.lastRow))...))
+	}
+	n, p := db.newPage()
+	writeTestPage(p, 0, 0x05, cells, leaves[len(leaves)-1].page)
+	return n
+}
+
+// leafCell Returns the cell of a row on a leaf page. The part of the payload that doesn't fit on the page is written
+// to overflow pages.
+func (db *testSQLite) leafCell(rowid int64, payload []byte) []byte {
+	cell := append(testVarint(uint64(len(payload))), testVarint(uint64(rowid))...)
+	usable := testPageSize
+	local := len(payload)
+	if maxLocal := usable - 35; local > maxLocal {
+		minLocal := (usable-12)*32/255 - 23
+		local = minLocal + (len(payload)-minLocal)%(usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+	next, p := db.newPage()
+	cell = binary.BigEndian.AppendUint32(cell, next)
+	for rest := payload[local:]; len(rest) > 0; {
+		n := copy(p[4:], rest)
+		if rest = rest[n:]; len(rest) > 0 {
+			next, overflow := db.newPage()
+			binary.BigEndian.PutUint32(p, next)
+			p = overflow
+		}
+	}
+	return cell
+}
+
+// writeTestPage Writes the header and the cells of a b-tree page. The cells are written to the end of the page.
+func writeTestPage(p []byte, header int, pageType byte, cells [][]byte, rightChild uint32) {
+	p[header] = pageType
+	pointers := header + 8
+	if pageType == 0x05 {
+		binary.BigEndian.PutUint32(p[header+8:], rightChild)
+		pointers = header + 12
+	}
+	binary.BigEndian.PutUint16(p[header+3:], uint16(len(cells)))
+	end := len(p)
+	for i, cell := range cells {
+		end -= len(cell)
+		copy(p[end:], cell)
+		binary.BigEndian.PutUint16(p[pointers+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(p[header+5:], uint16(end))
+}
+
+// testRecord Encodes the values as a record. Integers are stored in 8 bytes.
+func testRecord(values ...any) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int64:
+			types = append(types, 6)
+			body = binary.BigEndian.AppendUint64(body, uint64(v))
+		case float64:
+			types = append(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = append(types, testVarint(uint64(2*len(v)+13))...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, testVarint(uint64(2*len(v)+12))...)
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("unsupported value %T", v))
+		}
+	}
+	headerSize := len(types) + 1
+	if len(testVarint(uint64(headerSize))) > 1 {
+		headerSize++
+	}
+	record := append(testVarint(uint64(headerSize)), types...)
+	return append(record, body...)
+}
+
+// testVarint Encodes a variable-length integer of up to 56 bits.
+func testVarint(v uint64) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+func TestSQLiteTable(t *testing.T) {
+	var many [][]any
+	for i := 0; i < 500; i++ {
+		many = append(many, []any{int64(i), fmt.Sprintf("row %d", i), strings.Repeat("x", 50)})
+	}
+	long := strings.Repeat("long text ", 2000)
+	few := [][]any{
+		{nil, "text", int64(-2), 1.5, []byte{1, 2}},
+		{nil, long, int64(math.MaxInt64)},
+	}
+	db, err := openSQLite(newTestSQLite(testTable{"few", few}, testTable{"many", many}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !db.hasTable("many") || db.hasTable("missing") {
+		t.Error("hasTable() doesn't find the tables of the schema")
+	}
+
+	rows, err := db.table("few")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1].rowid != 2 {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	want := []any{nil, "text", int64(-2), 1.5, []byte{1, 2}}
+	if !reflect.DeepEqual(rows[0].values, want) {
+		t.Errorf("values = %#v, want %#v", rows[0].values, want)
+	}
+	// The payload of the second row continues on overflow pages.
+	if rows[1].text(1) != long || rows[1].int(2) != math.MaxInt64 {
+		t.Errorf("unexpected second row of %d bytes", len(rows[1].text(1)))
+	}
+
+	// The rows of many are on several leaf pages below an interior page.
+	rows, err = db.table("many")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(many) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(many))
+	}
+	for i, r := range rows {
+		if r.rowid != int64(i+1) || r.int(0) != int64(i) || r.text(1) != fmt.Sprintf("row %d", i) {
+			t.Fatalf("row %d: unexpected row %v", i, r)
+		}
+	}
+	if _, err = db.table("missing"); err == nil {
+		t.Error("table() of a missing table returns no error")
+	}
+}
+
+func TestSQLiteCorrupt(t *testing.T) {
+	// Cells that are written to the end of the second page, the only page of the table, with a cell pointer to them.
+	cell := func(b ...byte) func(p []byte) {
+		return func(p []byte) {
+			copy(p[len(p)-len(b):], b)
+			binary.BigEndian.PutUint16(p[3:], 1)
+			binary.BigEndian.PutUint16(p[8:], uint16(len(p)-len(b)))
+		}
+	}
+	tests := []struct {
+		name    string
+		corrupt func(p []byte)
+		wantErr string
+	}{
+		{"cell pointers beyond the page", func(p []byte) {
+			// Each cell pointer points to a valid cell, but there are more pointers than fit on the page.
+			for i := 8; i < len(p); i++ {
+				p[i] = 0x01
+			}
+			binary.BigEndian.PutUint16(p[3:], 0x0900)
+		}, "invalid number of cells on page 2"},
+		{"cell offset beyond the page", func(p []byte) {
+			binary.BigEndian.PutUint16(p[8:], 0xffff)
+		}, "invalid cell offset on page 2"},
+		{"cell offset in the page header", func(p []byte) {
+			binary.BigEndian.PutUint16(p[8:], 2)
+		}, "invalid cell offset on page 2"},
+		{"payload size beyond the database", cell(0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01),
+			"invalid cell size"},
+		{"serial type beyond the record", cell(10, 1, 10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f),
+			"invalid record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newTestSQLite(testTable{"t", [][]any{{int64(1), "one"}}})
+			tt.corrupt(data[testPageSize : 2*testPageSize])
+			db, err := openSQLite(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = db.table("t"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("table() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSQLiteCorruptByte(t *testing.T) {
+	var rows [][]any
+	for i := 0; i < 100; i++ {
+		rows = append(rows, []any{int64(i), strings.Repeat("y", 40)})
+	}
+	rows = append(rows, []any{int64(100), strings.Repeat("z", 5000)})
+	data := newTestSQLite(testTable{"t", rows})
+
+	// Any corrupt byte results in an error or wrong values, but never in a panic.
+	for i, original := range data {
+		for _, b := range []byte{0x00, 0x7f, 0xff} {
+			if b == original {
+				continue
+			}
+			data[i] = b
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("byte %d set to %#x: %v", i, b, r)
+					}
+				}()
+				if db, err := openSQLite(data); err == nil {
+					_, _ = db.table("t")
+				}
+			}()
+		}
+		data[i] = original
+	}
+}
+
+func TestReadVarint(t *testing.T) {
+	tests := []struct {
+		b      []byte
+		want   uint64
+		length int
+	}{
+		{[]byte{0x05}, 5, 1},
+		{[]byte{0x81, 0x00}, 128, 2},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, math.MaxUint64, 9},
+		{[]byte{0x81}, 1, 1},
+	}
+	for _, tt := range tests {
+		if got, n := readVarint(tt.b); got != tt.want || n != tt.length {
+			t.Errorf("readVarint(%x) = %d, %d, want %d, %d", tt.b, got, n, tt.want, tt.length)
+		}
+	}
+	for _, v := range []uint64{0, 127, 128, 1 << 20, 1<<56 - 1} {
+		if got, _ := readVarint(testVarint(v)); got != v {
+			t.Errorf("readVarint(testVarint(%d)) = %d", v, got)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// statsPeriodDays is the number of days over which the recent statistics are calculated.
+const statsPeriodDays = 30
+
+// numberHardestCards is the maximum number of cards shown in the list of hardest cards.
+const numberHardestCards = 5
+
+// cardStats holds the review statistics of a single card.
+type cardStats struct {
+	card            *Card
+	id              string
+	reviews, lapses int
+	hard            int
+}
+
+// retention Returns the share of reviews that were remembered, or 0 if there are no reviews.
+func retention(reviews []Review) float64 {
+	if len(reviews) == 0 {
+		return 0
+	}
+	remembered := 0
+	for _, r := range reviews {
+		if r.Grade != GradeNotRemembered {
+			remembered++
+		}
+	}
+	return float64(remembered) / float64(len(reviews))
+}
+
+// streaks Returns the number of consecutive days with at least one review up to today (or yesterday, if nothing was
+// reviewed today yet), and the longest number of consecutive days with reviews.
+func streaks(days map[string]int, today time.Time) (current, longest int) {
+	dates := make([]string, 0, len(days))
+	for d := range days {
+		dates = append(dates, d)
+	}
+	slices.Sort(dates)
+	run := 0
+	var prev time.Time
+	for _, d := range dates {
+		t, _ := time.Parse("2006-01-02", d)
+		if !prev.IsZero() && t.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = t
+	}
+
+	day := today
+	if days[day.Format("2006-01-02")] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day.Format("2006-01-02")] > 0 {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+// PrintStats Prints statistics about the review history of the session's files: the retention rate, reviews per
+// day, streaks, the hardest cards, and the number of cards that will be due per category in the next days.
+func (s *Session) PrintStats(forecastDays int) error {
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	periodStart := today.AddDate(0, 0, -statsPeriodDays+1)
+
+	var all, recent []Review
+	var totalDurationMs int64
+	reviewsPerDay := make(map[string]int)
+	var perCard []*cardStats
+	for i := range s.Files {
+		f := &s.Files[i]
+		history, err := ReadHistory(f.Path)
+		if err != nil {
+			return err
+		}
+		byId := make(map[string]*cardStats)
+		for j := range f.Cards {
+			c := &f.Cards[j]
+			byId[c.Id] = &cardStats{card: c, id: c.Id}
+			perCard = append(perCard, byId[c.Id])
+		}
+		for _, r := range history {
+			day := r.Time.Local().Format("2006-01-02")
+			reviewsPerDay[day]++
+			all = append(all, r)
+			totalDurationMs += r.DurationMs
+			if day >= periodStart.Format("2006-01-02") {
+				recent = append(recent, r)
+			}
+
+			cs, ok := byId[r.Id]
+			if !ok {
+				// The card has been deleted from the file.
+				cs = &cardStats{id: r.Id}
+				byId[r.Id] = cs
+				perCard = append(perCard, cs)
+			}
+			cs.reviews++
+			switch r.Grade {
+			case GradeNotRemembered:
+				cs.lapses++
+			case GradeHard:
+				cs.hard++
+			}
+		}
+	}
+
+	if len(all) == 0 {
+		fmt.Print("No reviews yet.\n\n")
+	} else {
+		current, longest := streaks(reviewsPerDay, today)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Reviews:\t%d\t(last %d days: %d)\n", len(all), statsPeriodDays, len(recent))
+		fmt.Fprintf(w, "Retention rate:\t%.1f%%\t(last %d days: %.1f%%)\n", retention(all)*100, statsPeriodDays,
+			retention(recent)*100)
+		fmt.Fprintf(w, "Reviews per day:\t%.1f\t(average of the last %d days)\n",
+			float64(len(recent))/statsPeriodDays, statsPeriodDays)
+		fmt.Fprintf(w, "Time per review:\t%.1fs\t(average)\n", float64(totalDurationMs)/float64(len(all))/1000)
+		fmt.Fprintf(w, "Current streak:\t%d days\t\n", current)
+		fmt.Fprintf(w, "Longest streak:\t%d days\t\n", longest)
+		w.Flush()
+
+		fmt.Println("\nReviews of the last 7 days:")
+		for i := 6; i >= 0; i-- {
+			day := today.AddDate(0, 0, -i).Format("2006-01-02")
+			n := reviewsPerDay[day]
+			bar := n
+			if bar > 50 {
+				bar = 50
+			}
+			fmt.Printf("  %s  %4d  %s\n", day, n, strings.Repeat("#", bar))
+		}
+
+		// Sort by the number of lapses, then by the number of hard answers.
+		slices.SortStableFunc(perCard, func(a, b *cardStats) int {
+			if a.lapses != b.lapses {
+				return b.lapses - a.lapses
+			}
+			return b.hard - a.hard
+		})
+		fmt.Println("\nHardest cards:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		shown := 0
+		for _, cs := range perCard {
+			if shown == numberHardestCards || cs.lapses+cs.hard == 0 {
+				break
+			}
+			front := fmt.Sprintf("[%s] (deleted)", cs.id)
+			if cs.card != nil {
+				front = fmt.Sprintf("[%s] %s", cs.id, cs.card.title())
+				if len([]rune(front)) > 60 {
+					front = string([]rune(front)[:59]) + "…"
+				}
+			}
+			fmt.Fprintf(w, "  %s\t%d not remembered\t%d hard\t%d reviews\n", front, cs.lapses, cs.hard, cs.reviews)
+			shown++
+		}
+		w.Flush()
+		if shown == 0 {
+			fmt.Println("  None so far.")
+		}
+	}
+
+	s.printForecast(today, forecastDays)
+	return nil
+}
+
+// printForecast Prints the number of cards that are due per category for each of the next days. Cards whose due date
+// was missed are counted for today.
+func (s *Session) printForecast(today time.Time, days int) {
+	var categories []string
+	counts := make(map[string][]int)
+	total := make([]int, days)
+	for _, c := range s.cards() {
+		if !CompareCategory(c.Category, s.Category) {
+			continue
+		}
+		if _, ok := counts[c.Category]; !ok {
+			categories = append(categories, c.Category)
+			counts[c.Category] = make([]int, days)
+		}
+		day := int(c.Due.Sub(today).Hours() / 24)
+		if day < 0 {
+			day = 0
+		}
+		if day < days {
+			counts[c.Category][day]++
+			total[day]++
+		}
+	}
+
+	fmt.Printf("\nForecast of due cards for the next %d days:\n", days)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "Category\tToday\t")
+	for i := 1; i < days; i++ {
+		fmt.Fprintf(w, "+%d\t", i)
+	}
+	fmt.Fprintln(w)
+	printRow := func(name string, row []int) {
+		fmt.Fprintf(w, "%s\t", name)
+		for _, n := range row {
+			fmt.Fprintf(w, "%d\t", n)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, category := range categories {
+		printRow(category, counts[category])
+	}
+	printRow("Total", total)
+	w.Flush()
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreaks(t *testing.T) {
+	today := time.Date(2023, 3, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name                     string
+		days                     []string
+		wantCurrent, wantLongest int
+	}{
+		{"empty history", nil, 0, 0},
+		{"ending today", []string{"2023-03-08", "2023-03-09", "2023-03-10"}, 3, 3},
+		{"ending yesterday", []string{"2023-03-08", "2023-03-09"}, 2, 2},
+		{"ended before yesterday", []string{"2023-03-07", "2023-03-08"}, 0, 2},
+		{"gap", []string{"2023-03-01", "2023-03-02", "2023-03-03", "2023-03-04", "2023-03-09", "2023-03-10"}, 2, 4},
+		{"across months", []string{"2023-02-27", "2023-02-28", "2023-03-01", "2023-03-10"}, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days := make(map[string]int)
+			for _, d := range tt.days {
+				days[d] = 2
+			}
+			current, longest := streaks(days, today)
+			if current != tt.wantCurrent || longest != tt.wantLongest {
+				t.Errorf("streaks() = %d, %d, want %d, %d", current, longest, tt.wantCurrent, tt.wantLongest)
+			}
+		})
+	}
+}
+
+func TestRetention(t *testing.T) {
+	tests := []struct {
+		grades []string
+		want   float64
+	}{
+		{nil, 0},
+		{[]string{GradeOkay}, 1},
+		{[]string{GradeNotRemembered}, 0},
+		// Hard and easy reviews were remembered as well.
+		{[]string{GradeHard, GradeEasy, GradeOkay, GradeNotRemembered}, 0.75},
+	}
+	for _, tt := range tests {
+		var reviews []Review
+		for _, g := range tt.grades {
+			reviews = append(reviews, Review{Grade: g})
+		}
+		if got := retention(reviews); got != tt.want {
+			t.Errorf("retention(%v) = %v, want %v", tt.grades, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Key is a key press read from the terminal in raw mode.
+type Key struct {
+	// Rune is the character of the key, or 0 for special keys.
+	Rune    rune
+	Special SpecialKey
+}
+
+type SpecialKey int
+
+const (
+	KeyNone SpecialKey = iota
+	KeyEnter
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyInterrupt
+	KeyEOF
+	// KeyUndo is Ctrl-Z, which doesn't suspend the process in raw mode.
+	KeyUndo
+)
+
+// Terminal is the full-screen interface of a study session. It switches the terminal into raw mode, so that single
+// key presses can be read without waiting for enter, and draws on the alternate screen buffer, so that the previous
+// content of the terminal is restored when the session ends.
+type Terminal struct {
+	in, out *os.File
+	state   *term.State
+	keys    chan Key
+	resize  chan os.Signal
+	signals chan os.Signal
+	// Reads the input until it is cancelled by Close.
+	reader *keyReader
+	// Closed by Close to stop readKeys, and closed by readKeys when it returns.
+	quit, stopped chan struct{}
+	closeOnce     sync.Once
+	// Called before the process exits on Ctrl-C or a signal, e.g. to release the locks of the files.
+	onExit func()
+}
+
+// IsInteractive Returns true if both standard input and output are terminals, i.e. if the full-screen interface can
+// be used.
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// OpenTerminal Switches the terminal into raw mode and to the alternate screen buffer. Close must be called to
+// restore the terminal's state.
+func OpenTerminal() (*Terminal, error) {
+	t := &Terminal{in: os.Stdin, out: os.Stdout, keys: make(chan Key), resize: make(chan os.Signal, 1),
+		signals: make(chan os.Signal, 1), quit: make(chan struct{}), stopped: make(chan struct{})}
+	reader, err := newKeyReader(t.in)
+	if err != nil {
+		return nil, err
+	}
+	t.reader = reader
+	state, err := term.MakeRaw(int(t.in.Fd()))
+	if err != nil {
+		reader.close()
+		return nil, err
+	}
+	t.state = state
+	// Switch to the alternate screen and hide the cursor.
+	fmt.Fprint(t.out, "\033[?1049h\033[?25l")
+
+	notifyResize(t.resize)
+	// Restore the terminal if the process is terminated from the outside. Ctrl-C is read as a key in raw mode.
+	signal.Notify(t.signals, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		if _, ok := <-t.signals; ok {
+			t.Close()
+			t.exit(1)
+		}
+	}()
+	go t.readKeys()
+	return t, nil
+}
+
+// Close Restores the terminal's state and stops reading key presses, so that the input is left to the next prompt.
+// It may be called several times and from several goroutines, e.g. the one that handles signals.
+func (t *Terminal) Close() {
+	t.closeOnce.Do(func() {
+		signal.Stop(t.resize)
+		signal.Stop(t.signals)
+		close(t.signals)
+		close(t.quit)
+		if t.reader.cancel() {
+			<-t.stopped
+		}
+		t.reader.close()
+		fmt.Fprint(t.out, "\033[?25h\033[?1049l")
+		_ = term.Restore(int(t.in.Fd()), t.state)
+	})
+}
+
+// exit Calls onExit and exits the process with the given status code.
+func (t *Terminal) exit(code int) {
+	if t.onExit != nil {
+		t.onExit()
+	}
+	os.Exit(code)
+}
+
+// readKeys Reads key presses from the terminal and sends them to the keys channel until the terminal is closed.
+func (t *Terminal) readKeys() {
+	defer close(t.stopped)
+	send := func(k Key) bool {
+		select {
+		case t.keys <- k:
+			return true
+		case <-t.quit:
+			return false
+		}
+	}
+	buf := make([]byte, 32)
+	for {
+		n, err := t.reader.read(buf)
+		if err == errReadCancelled {
+			return
+		}
+		if err != nil || n == 0 {
+			send(Key{Special: KeyEOF})
+			return
+		}
+		for _, k := range parseKeys(string(buf[:n])) {
+			if !send(k) {
+				return
+			}
+		}
+	}
+}
+
+// errReadCancelled is returned by keyReader.read after the reader was cancelled.
+var errReadCancelled = errors.New("read cancelled")
+
+// escapeSequences are the escape sequences of the special keys that are recognized.
+var escapeSequences = map[string]SpecialKey{"\033[A": KeyUp, "\033OA": KeyUp, "\033[B": KeyDown, "\033OB": KeyDown}
+
+// parseKeys Returns the key presses of the input read at once. Escape sequences of other special keys, e.g. of
+// function keys, are skipped; an escape that doesn't start a sequence is the escape key. All other bytes, including
+// the ones after an escape sequence, are passed through as keys.
+func parseKeys(in string) []Key {
+	var keys []Key
+	for len(in) > 0 {
+		if in[0] == '\033' {
+			n := escapeSequenceLen(in)
+			if n == 1 {
+				keys = append(keys, Key{Special: KeyEscape})
+			} else if k, ok := escapeSequences[in[:n]]; ok {
+				keys = append(keys, Key{Special: k})
+			}
+			in = in[n:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(in)
+		in = in[size:]
+		switch r {
+		case 3:
+			keys = append(keys, Key{Special: KeyInterrupt})
+		case 4:
+			keys = append(keys, Key{Special: KeyEOF})
+		case 26:
+			keys = append(keys, Key{Special: KeyUndo})
+		case '\r', '\n':
+			keys = append(keys, Key{Special: KeyEnter})
+		case 127, 8:
+			keys = append(keys, Key{Special: KeyBackspace})
+		default:
+			keys = append(keys, Key{Rune: r})
+		}
+	}
+	return keys
+}
+
+// escapeSequenceLen Returns the length of the escape sequence at the start of the input: a CSI sequence (`ESC [`,
+// parameters, and a final byte), an SS3 sequence (`ESC O` and one byte), or 1 for an escape that doesn't start one.
+func escapeSequenceLen(in string) int {
+	if len(in) < 3 {
+		return 1
+	}
+	switch in[1] {
+	case 'O':
+		return 3
+	case '[':
+		for i := 2; i < len(in); i++ {
+			if in[i] >= 0x40 && in[i] <= 0x7e {
+				return i + 1
+			}
+			if in[i] < 0x20 || in[i] > 0x3f {
+				// Not a parameter or intermediate byte, so the sequence is incomplete.
+				return 1
+			}
+		}
+	}
+	return 1
+}
+
+// ReadKey Blocks until a key is pressed. If the terminal is resized in the meantime, redraw is called. On Ctrl-C the
+// terminal is restored and the process exits, since all answers are already saved.
+func (t *Terminal) ReadKey(redraw func()) Key {
+	for {
+		select {
+		case k := <-t.keys:
+			if k.Special == KeyInterrupt {
+				t.Close()
+				fmt.Println("Session interrupted. Your answers so far have been saved.")
+				t.exit(130)
+			}
+			return k
+		case <-t.resize:
+			redraw()
+		}
+	}
+}
+
+// Size Returns the width and height of the terminal.
+func (t *Terminal) Size() (width, height int) {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// screen is the content of the terminal during a study session.
+type screen struct {
+	// Number of cards that have been answered and the total number of cards of the session.
+	done, total int
+	// Category, box, and due date of the card.
+	status string
+	body   string
+	// Key hints shown at the bottom of the screen.
+	hints string
+	// Number of lines of the body that are scrolled out of view.
+	scroll int
+}
+
+// progressBar Returns a progress bar of the given width.
+func progressBar(done, total, width int) string {
+	if total == 0 || width <= 0 {
+		return ""
+	}
+	filled := done * width / total
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// Draw Draws the screen. The body is cut off if it doesn't fit the terminal; the returned value is the maximum
+// scroll position.
+func (t *Terminal) Draw(sc screen) (maxScroll int) {
+	width, height := t.Size()
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+
+	// Progress bar at the top.
+	counter := fmt.Sprintf(" %d / %d ", sc.done, sc.total)
+	b.WriteString(counter)
+	b.WriteString(progressBar(sc.done, sc.total, width-len(counter)-1))
+	b.WriteString("\r\n\r\n")
+
+	// The body between the progress bar and the status line.
+	lines := strings.Split(strings.TrimRight(sc.body, "\n"), "\n")
+	available := height - 5
+	if available < 1 {
+		available = 1
+	}
+	maxScroll = len(lines) - available
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	scroll := sc.scroll
+	if scroll > maxScroll {
+		scroll = maxScroll
+	}
+	end := scroll + available
+	if end > len(lines) {
+		end = len(lines)
+	}
+	for _, line := range lines[scroll:end] {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	// Key hints and status line at the bottom.
+	hints := sc.hints
+	if maxScroll > 0 {
+		hints += "  ↑/↓: scroll"
+	}
+	fmt.Fprintf(&b, "\033[%d;1H\033[2m%s\033[0m", height-1, truncate(hints, width))
+	status := truncate(" "+sc.status, width)
+	fmt.Fprintf(&b, "\033[%d;1H\033[7m%s%s\033[0m", height, status, strings.Repeat(" ", width-len([]rune(status))))
+	fmt.Fprint(t.out, b.String())
+	return maxScroll
+}
+
+// truncate Cuts the string off at the given number of runes.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
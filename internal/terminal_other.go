@@ -0,0 +1,31 @@
+//go:build !unix
+
+package internal
+
+import "os"
+
+// notifyResize Does nothing since there is no resize signal on this platform. The screen is redrawn with the new size
+// on the next key press.
+func notifyResize(c chan os.Signal) {}
+
+// keyReader reads the input of the terminal. A pending read can't be cancelled on this platform.
+type keyReader struct {
+	in *os.File
+}
+
+func newKeyReader(in *os.File) (*keyReader, error) {
+	return &keyReader{in: in}, nil
+}
+
+// read Reads the input.
+func (k *keyReader) read(buf []byte) (int, error) {
+	return k.in.Read(buf)
+}
+
+// cancel Returns false since a pending read doesn't stop until the next key press.
+func (k *keyReader) cancel() bool {
+	return false
+}
+
+// close Does nothing.
+func (k *keyReader) close() {}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Key
+	}{
+		{"a", []Key{{Rune: 'a'}}},
+		{"\033", []Key{{Special: KeyEscape}}},
+		{"\033[A\033OB", []Key{{Special: KeyUp}, {Special: KeyDown}}},
+		// Unknown sequences, e.g. of F1 and Delete, are skipped, but the input after them is kept.
+		{"\033OPab", []Key{{Rune: 'a'}, {Rune: 'b'}}},
+		{"\033[3~x\r", []Key{{Rune: 'x'}, {Special: KeyEnter}}},
+		{"\033x", []Key{{Special: KeyEscape}, {Rune: 'x'}}},
+		{"ä\x7f\x1a\x03", []Key{{Rune: 'ä'}, {Special: KeyBackspace}, {Special: KeyUndo}, {Special: KeyInterrupt}}},
+	}
+	for _, tt := range tests {
+		if got := parseKeys(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
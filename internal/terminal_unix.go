@@ -0,0 +1,66 @@
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// notifyResize Sends a signal to the channel when the terminal is resized.
+func notifyResize(c chan os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
+
+// keyReader reads the input of the terminal until it is cancelled. Cancelling closes the write end of a pipe, which
+// wakes up the poll that waits for the input.
+type keyReader struct {
+	in               *os.File
+	cancelR, cancelW *os.File
+}
+
+func newKeyReader(in *os.File) (*keyReader, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &keyReader{in: in, cancelR: r, cancelW: w}, nil
+}
+
+// read Waits until the input is readable and reads it. It returns errReadCancelled once the reader is cancelled.
+func (k *keyReader) read(buf []byte) (int, error) {
+	fds := []unix.PollFd{
+		{Fd: int32(k.in.Fd()), Events: unix.POLLIN},
+		{Fd: int32(k.cancelR.Fd()), Events: unix.POLLIN},
+	}
+	for {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if fds[1].Revents != 0 {
+			return 0, errReadCancelled
+		}
+		if fds[0].Revents != 0 {
+			return k.in.Read(buf)
+		}
+	}
+}
+
+// cancel Stops a pending and all following reads. It returns true since the reader always stops.
+func (k *keyReader) cancel() bool {
+	_ = k.cancelW.Close()
+	return true
+}
+
+// close Releases the pipe of the reader.
+func (k *keyReader) close() {
+	_ = k.cancelW.Close()
+	_ = k.cancelR.Close()
+}
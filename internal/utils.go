@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -25,45 +24,52 @@ func ClearConsole() {
 // when clearing the console.
 func ScrollDownScreen() {
 	_, height, err := term.GetSize(int(os.Stdout.Fd()))
-	check(err)
+	if err != nil {
+		// Not a terminal, nothing to scroll.
+		return
+	}
 	for i := 0; i < height; i++ {
 		fmt.Println()
 	}
 }
 
-func check(e error) {
-	if e != nil {
-		panic(e)
-	}
-}
-
 // PrintJSON pretty prints any struct as JSON
 func PrintJSON[T any](v T) {
 	out, _ := json.MarshalIndent(v, "", "  ")
 	fmt.Println(string(out))
 }
 
+// stdin is shared by all functions that read from standard input, so that no buffered input gets lost if the input
+// is piped.
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadLineInput reads a line from standard input and returns it without the line break.
+func ReadLineInput() string {
+	line, _ := readLine()
+	return line
+}
+
+// readLine reads a line from standard input and returns it without the line break. eof is true if standard input
+// was closed before a line break was read.
+func readLine() (line string, eof bool) {
+	line, err := stdin.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err != nil
+}
+
 // ReadNumberInput reads a number from standard input. The number must be within i and j. If it is not, it will retry.
 func ReadNumberInput(i, j int) int {
-	res := i - 1
-	scanner := bufio.NewScanner(os.Stdin)
-	for res < i || res > j {
-		scanner.Scan()
-		in := scanner.Text()
-		nr, err := strconv.Atoi(in)
-		if err != nil || nr < i || nr > j {
-			fmt.Print("Please enter a number: ")
-			continue
+	for {
+		nr, err := strconv.Atoi(strings.TrimSpace(ReadLineInput()))
+		if err == nil && nr >= i && nr <= j {
+			return nr
 		}
-		res = nr
+		fmt.Print("Please enter a number: ")
 	}
-	return res
 }
 
 // ReadEnterInput Blocks until the user enters a newline.
 func ReadEnterInput() {
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
+	ReadLineInput()
 }
 
 // CompareCategory compares the category name to the user input and returns true if the input matches with the
@@ -96,51 +102,3 @@ func FindClosestDate(cards []Card) (time.Time, error) {
 	}
 	return closestDate, nil
 }
-
-// WrapLines wraps the given string into lines of the given length.
-// It will not break words and thus only breaks at whitespace. It assumes that no word in the given string exceeds the
-// requested line length. Lines that start with an indent will be indented by the given indent plus, if the line is
-// a list item, the length of the list item prefix.
-//
-// If the lineLength is 0, it will wrap the text depending on the terminal width.
-func WrapLines(s string, lineLength uint) string {
-	if lineLength == 0 {
-		width, _, err := term.GetSize(int(os.Stdout.Fd()))
-		check(err)
-		lineLength = uint(width)
-	}
-
-	lineFeedRegex := regexp.MustCompile("\r?\n")
-	indentRegex := regexp.MustCompile(`(?m)^[\-+*\d.\s]+`)
-	lineBreakRegex := regexp.MustCompile(fmt.Sprintf(`(?m)^.{1,%d}\s`, lineLength))
-
-	var result string
-	lines := lineFeedRegex.Split(s, -1)
-	for _, line := range lines {
-		if len(line) == 0 {
-			result += "\n"
-		}
-
-		linePrefix := indentRegex.FindString(line)
-		lineIndent := len(linePrefix)
-		for len(line) > 0 {
-			if uint(len(line)) <= lineLength {
-				result += line + "\n"
-				break
-			} else {
-				idx := len(line)
-				idxs := lineBreakRegex.FindStringIndex(line)
-				if idxs != nil {
-					idx = idxs[1]
-				}
-				result += line[:idx] + "\n"
-				remainder := strings.TrimSpace(line[idx:])
-				remainderLen := len([]rune(remainder))
-				paddingFmt := fmt.Sprintf("%%%ds", lineIndent+remainderLen)
-				line = fmt.Sprintf(paddingFmt, remainder)
-			}
-		}
-	}
-
-	return result
-}
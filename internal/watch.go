@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"slices"
+	"time"
+)
+
+// watcher watches the files of a session for changes by other programs, e.g. an editor.
+type watcher interface {
+	// changed Returns the paths of the files that may have changed since the last call.
+	changed() []string
+	close()
+}
+
+// fileStamp is the modification time and size of a file. A file is considered changed if its stamp is different.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// pollWatcher watches the files by comparing their stamps whenever it is asked for changes. It is used if the
+// platform can't notify about changes.
+type pollWatcher struct {
+	paths  []string
+	stamps map[string]fileStamp
+}
+
+func newPollWatcher(paths []string) *pollWatcher {
+	w := &pollWatcher{paths: paths, stamps: make(map[string]fileStamp)}
+	w.changed()
+	return w
+}
+
+func (w *pollWatcher) changed() (paths []string) {
+	for _, p := range w.paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+		if previous, ok := w.stamps[p]; ok && previous != stamp {
+			paths = append(paths, p)
+		}
+		w.stamps[p] = stamp
+	}
+	return paths
+}
+
+func (w *pollWatcher) close() {}
+
+// watchFiles Starts watching the session's files, so that changes can be reloaded between cards.
+func (s *Session) watchFiles() {
+	paths := make([]string, len(s.Files))
+	for i, f := range s.Files {
+		paths[i] = f.Path
+	}
+	s.watcher = newWatcher(paths)
+}
+
+// reloadChangedFiles Reloads the files that were changed by another program since the last call.
+func (s *Session) reloadChangedFiles() error {
+	if s.watcher == nil {
+		return nil
+	}
+	for _, path := range s.watcher.changed() {
+		for i := range s.Files {
+			if s.Files[i].Path != path {
+				continue
+			}
+			if err := s.reloadFile(&s.Files[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reloadFile Reads the file again if its content was changed by another program. Cards are matched by their ID:
+// changed fronts and backs are taken over, deleted cards are removed from the study queue and the undo history, and
+// new cards are added to the study queue if they are due. The metadata of the cards in memory is kept and written
+// back to the file if it differs, e.g. because an editor saved an older version of the file. The front matter is not
+// reloaded.
+func (s *Session) reloadFile(f *File) error {
+	data, err := os.ReadFile(f.Path)
+	if err != nil || string(data) == f.content {
+		return nil
+	}
+	reloaded, err := readFile(f.Path, !s.ReadOnly)
+	if errors.Is(err, ErrIO) {
+		return err
+	} else if err != nil {
+		// Keep the cards as they are until the file is valid again.
+		return nil
+	}
+
+	previous := make(map[string]*Card)
+	for i := range f.Cards {
+		previous[f.Cards[i].Id] = &f.Cards[i]
+	}
+	cards := reloaded.Cards
+	// Maps the previous cards to the reloaded ones. Deleted cards are missing.
+	reloadedCards := make(map[*Card]*Card)
+	var added, outdated []*Card
+	for i := range cards {
+		c := &cards[i]
+		p, ok := previous[c.Id]
+		if !ok {
+			added = append(added, c)
+			continue
+		}
+		reloadedCards[p] = c
+		if formatMetadata(c) != formatMetadata(p) {
+			content := *c
+			*c = *p
+			c.setContent(content)
+			outdated = append(outdated, c)
+		}
+	}
+	f.Cards = cards
+	f.Warnings = reloaded.Warnings
+	f.content = reloaded.content
+	if !s.TestMode {
+		for _, c := range outdated {
+			if err = s.updateCardInFile(c); err != nil {
+				return err
+			}
+		}
+	}
+
+	remap := func(queue []*Card) []*Card {
+		result := make([]*Card, 0, len(queue))
+		for _, c := range queue {
+			if c.Path != f.Path {
+				result = append(result, c)
+			} else if r, ok := reloadedCards[c]; ok {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+	queued := len(s.studyQueue)
+	s.studyQueue = remap(s.studyQueue)
+	s.NumberCards -= uint(queued - len(s.studyQueue))
+	undoStack := s.undoStack[:0]
+	for _, e := range s.undoStack {
+		if e.card.Path == f.Path {
+			r, ok := reloadedCards[e.card]
+			if !ok {
+				continue
+			}
+			e.card = r
+		}
+		e.queue = remap(e.queue)
+		undoStack = append(undoStack, e)
+	}
+	s.undoStack = undoStack
+
+	for _, c := range added {
+		if s.reverseQueued(c) || !CompareCategory(c.Category, s.Category) {
+			continue
+		}
+		if due, _ := s.isDue(*c); !due && !s.TestMode {
+			continue
+		}
+		i := len(s.studyQueue)
+		if !s.Sequential {
+			i = rand.Intn(len(s.studyQueue) + 1)
+		}
+		s.studyQueue = slices.Insert(s.studyQueue, i, c)
+		s.NumberCards++
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package internal
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyWatcher watches the directories of the files with inotify. The directories are watched instead of the files
+// since editors often save a file by replacing it with a new one.
+type inotifyWatcher struct {
+	fd int
+	// Watched directories by their watch descriptor.
+	dirs map[int]string
+	// Paths of the files by their resolved path.
+	files map[string]string
+	buf   []byte
+}
+
+// newWatcher Returns a watcher of the given files. It falls back to polling if inotify is not available.
+func newWatcher(paths []string) watcher {
+	w, err := newInotifyWatcher(paths)
+	if err != nil {
+		return newPollWatcher(paths)
+	}
+	return w
+}
+
+func newInotifyWatcher(paths []string) (*inotifyWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{fd: fd, dirs: make(map[int]string), files: make(map[string]string),
+		buf: make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))}
+	for _, p := range paths {
+		resolved := p
+		if r, err := filepath.EvalSymlinks(p); err == nil {
+			resolved = r
+		}
+		w.files[resolved] = p
+		// Only complete writes are reported, so that a file is not read while it is written.
+		wd, err := syscall.InotifyAddWatch(fd, filepath.Dir(resolved), syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+		w.dirs[wd] = filepath.Dir(resolved)
+	}
+	return w, nil
+}
+
+func (w *inotifyWatcher) changed() (paths []string) {
+	for {
+		n, err := syscall.Read(w.fd, w.buf)
+		if err != nil || n <= 0 {
+			// EAGAIN: no more events.
+			return paths
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&w.buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			offset = nameStart + int(event.Len)
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				// Events were lost, so any file may have changed.
+				for _, p := range w.files {
+					if !slices.Contains(paths, p) {
+						paths = append(paths, p)
+					}
+				}
+				continue
+			}
+			name := strings.TrimRight(string(w.buf[nameStart:offset]), "\x00")
+			p, ok := w.files[filepath.Join(w.dirs[int(event.Wd)], name)]
+			if ok && !slices.Contains(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) close() {
+	syscall.Close(w.fd)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package internal
+
+// newWatcher Returns a watcher of the given files that polls them, since there is no inotify on this platform.
+func newWatcher(paths []string) watcher {
+	return newPollWatcher(paths)
+}
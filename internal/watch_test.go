@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadFile(t *testing.T) {
+	md := "# C\n\n## Q1 <!--mdfc:2;abcd1234;2;2023-03-01-->\n\nA1\n\n" +
+		"## Q2 <!--mdfc:2;efgh5678;0;2023-03-01-->\n\nA2\n\n" +
+		"## Q3 <!--mdfc:2;ijkl9012;0;2023-03-01-->\n\nA3\n"
+	path := filepath.Join(t.TempDir(), "deck.md")
+	writeTestFile(t, path, md)
+	s := &Session{Sequential: true}
+	if err := s.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.assembleStudyQueue()
+	answerTestCard(t, s, Okay)
+	day := func(days int) string {
+		return time.Now().AddDate(0, 0, days).Format("2006-01-02")
+	}
+	graded := strings.Replace(md, "abcd1234;2;2023-03-01", "abcd1234;3;"+day(4), 1)
+	if data, _ := os.ReadFile(path); string(data) != graded {
+		t.Fatalf("file after the first answer = %q, want %q", data, graded)
+	}
+
+	// An editor that still has the file before the answer changes the back of the second card, deletes the third
+	// card and adds a new one.
+	edited := strings.Replace(md, "A2", "A2 changed", 1)
+	edited = strings.Replace(edited, "## Q3 <!--mdfc:2;ijkl9012;0;2023-03-01-->\n\nA3\n", "## Q4\n\nA4\n", 1)
+	writeTestFile(t, path, edited)
+	if err := s.reloadFile(&s.Files[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.studyQueue) != 2 || s.studyQueue[0].Id != "efgh5678" || s.studyQueue[1].Front != "Q4" {
+		t.Fatalf("queue after reloading = %v", queueIds(s))
+	}
+	if s.NumberCards != 3 {
+		t.Errorf("number of cards = %d, want 3", s.NumberCards)
+	}
+	newId := s.studyQueue[1].Id
+	// The progress of the first card is written back, and the new card gets metadata.
+	reloaded := strings.Replace(edited, "abcd1234;2;2023-03-01", "abcd1234;3;"+day(4), 1)
+	reloaded = strings.Replace(reloaded, "## Q4", "## Q4 "+formatMetadata(s.studyQueue[1]), 1)
+	if data, _ := os.ReadFile(path); string(data) != reloaded {
+		t.Fatalf("file after reloading = %q, want %q", data, reloaded)
+	}
+
+	// The next card has the changed back side, and grading it changes the reloaded file.
+	c := answerTestCard(t, s, Okay)
+	if c.Back != "A2 changed" || c != &s.Files[0].Cards[1] {
+		t.Errorf("answered card %+v isn't the reloaded one", c)
+	}
+	reloaded = strings.Replace(reloaded, "efgh5678;0;2023-03-01", "efgh5678;1;"+day(1), 1)
+	if data, _ := os.ReadFile(path); string(data) != reloaded {
+		t.Errorf("file after the second answer = %q, want %q", data, reloaded)
+	}
+	if got := queueIds(s); !slices.Equal(got, []string{newId}) {
+		t.Errorf("queue = %v, want the new card %s", got, newId)
+	}
+	if len(s.Files[0].Cards) != 3 || s.Files[0].Cards[0].Box != 3 {
+		t.Errorf("cards after reloading = %+v", s.Files[0].Cards)
+	}
+}